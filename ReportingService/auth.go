@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Caller is the identity a /reporting request is authenticated as, together
+// with the businesses it may report on.
+type Caller struct {
+	Name       string
+	Businesses []string // business IDs, or "*" for every business
+}
+
+// allBusinesses in Caller.Businesses grants access to every business
+const allBusinesses = "*"
+
+// CanAccess reports whether the caller may report on businessID.
+func (c Caller) CanAccess(businessID string) bool {
+	for _, id := range c.Businesses {
+		if id == allBusinesses || id == businessID {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator checks the credentials carried by /reporting requests.
+type Authenticator interface {
+	// Authenticate returns the caller r carries valid credentials of,
+	// or errBadCredential.
+	Authenticate(r *http.Request) (Caller, error)
+	// Scheme is the HTTP authentication scheme callers are challenged with.
+	Scheme() string
+}
+
+// Authentication methods selectable with the -auth flag
+const (
+	authBasic  = "basic"
+	authAPIKey = "apikey"
+	authJWT    = "jwt"
+)
+
+// newAuthenticator returns the Authenticator of method, reading its
+// credentials from path.
+func newAuthenticator(method, path string) (Authenticator, error) {
+	file := &credentialsFile{path: path}
+	if err := file.load(); err != nil {
+		return nil, err
+	}
+	switch method {
+	case authBasic:
+		return basicAuthenticator{file}, nil
+	case authAPIKey:
+		return apiKeyAuthenticator{file}, nil
+	case authJWT:
+		return jwtAuthenticator{file}, nil
+	}
+	return nil, fmt.Errorf("unknown authentication method %q", method)
+}
+
+// basicAuthenticator checks HTTP Basic Auth against an htpasswd style file of
+// "user:bcrypt hash:businesses" lines, the hash made with e.g.
+// `htpasswd -nB user`.
+type basicAuthenticator struct {
+	users *credentialsFile
+}
+
+func (a basicAuthenticator) Authenticate(r *http.Request) (Caller, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return Caller{}, errBadCredential
+	}
+	cred, ok := a.users.lookup(user)
+	if !ok || bcrypt.CompareHashAndPassword([]byte(cred.secret), []byte(pass)) != nil {
+		return Caller{}, errBadCredential
+	}
+	return Caller{Name: user, Businesses: cred.businesses}, nil
+}
+
+func (basicAuthenticator) Scheme() string { return "Basic" }
+
+// apiKeyAuthenticator checks static API keys sent as
+// "Authorization: Bearer <key>" against a file of
+// "name:hex SHA-256 of key:businesses" lines, so the file does not hold
+// usable keys.
+type apiKeyAuthenticator struct {
+	keys *credentialsFile
+}
+
+func (a apiKeyAuthenticator) Authenticate(r *http.Request) (Caller, error) {
+	key, ok := bearerToken(r)
+	if !ok {
+		return Caller{}, errBadCredential
+	}
+	sum := sha256.Sum256([]byte(key))
+	digest := []byte(hex.EncodeToString(sum[:]))
+	for name, cred := range a.keys.all() {
+		if subtle.ConstantTimeCompare(digest, []byte(strings.ToLower(cred.secret))) == 1 {
+			return Caller{Name: name, Businesses: cred.businesses}, nil
+		}
+	}
+	return Caller{}, errBadCredential
+}
+
+func (apiKeyAuthenticator) Scheme() string { return "Bearer" }
+
+// jwtAuthenticator checks HMAC-signed JWTs sent as
+// "Authorization: Bearer <token>". The token's "kid" header selects the key
+// from a file of "kid:base64 secret" lines, its "sub" claim names the caller
+// and its "businesses" claim lists the businesses it may report on. Tokens
+// must carry an expiry claim; a not-before claim is enforced when present.
+type jwtAuthenticator struct {
+	keys *credentialsFile
+}
+
+type jwtClaims struct {
+	Businesses []string `json:"businesses"`
+	jwt.RegisteredClaims
+}
+
+func (a jwtAuthenticator) Authenticate(r *http.Request) (Caller, error) {
+	raw, ok := bearerToken(r)
+	if !ok {
+		return Caller{}, errBadCredential
+	}
+	var claims jwtClaims
+	token, err := jwt.ParseWithClaims(raw, &claims, a.key, jwt.WithExpirationRequired())
+	if err != nil || !token.Valid || claims.Subject == "" {
+		return Caller{}, errBadCredential
+	}
+	return Caller{Name: claims.Subject, Businesses: claims.Businesses}, nil
+}
+
+// key is the jwt.Keyfunc returning the secret of the token's key ID.
+func (a jwtAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errBadCredential
+	}
+	kid, _ := token.Header["kid"].(string)
+	cred, ok := a.keys.lookup(kid)
+	if !ok {
+		return nil, errBadCredential
+	}
+	return base64.StdEncoding.DecodeString(cred.secret)
+}
+
+func (jwtAuthenticator) Scheme() string { return "Bearer" }
+
+// authorizingMiddleware refuses reports on businesses out of the caller's
+// scope, before any POS data is fetched for them.
+type authorizingMiddleware struct {
+	next ReportingService
+}
+
+func (mw authorizingMiddleware) reporting(ctx context.Context, req ReportRequest) (Report, error) {
+	if !req.Caller.CanAccess(req.BusinessID) {
+		return Report{}, errForbidden
+	}
+	return mw.next.reporting(ctx, req)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+// credential is a line of a credentialsFile.
+type credential struct {
+	secret     string
+	businesses []string
+}
+
+// credentialsFile is a file of "name:secret:businesses" lines, businesses
+// being a comma-separated list of business IDs, or * for every business. A
+// credential without businesses may not report on any. Blank lines and lines
+// starting with # are skipped. The file is read again whenever it changes on
+// disk, so credentials are added and revoked without a restart.
+type credentialsFile struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	entries map[string]credential
+}
+
+func (f *credentialsFile) lookup(name string) (credential, bool) {
+	cred, ok := f.all()[name]
+	return cred, ok
+}
+
+// all returns the current entries. If the file cannot be read again, the
+// entries last read stay in use.
+func (f *credentialsFile) all() map[string]credential {
+	f.load()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entries
+}
+
+func (f *credentialsFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info.ModTime().Equal(f.modTime) && f.entries != nil {
+		return nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries := make(map[string]credential)
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("%s:%d: want name:secret:businesses", f.path, n)
+		}
+		cred := credential{secret: parts[1]}
+		if len(parts) == 3 {
+			for _, id := range strings.Split(parts[2], ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					cred.businesses = append(cred.businesses, id)
+				}
+			}
+		}
+		entries[parts[0]] = cred
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	f.entries, f.modTime = entries, info.ModTime()
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/base64"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var jwtSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newJWTAuthenticator(t *testing.T) Authenticator {
+	path := filepath.Join(t.TempDir(), "jwt.keys")
+	keys := "k1:" + base64.StdEncoding.EncodeToString(jwtSecret) + "\n"
+	if err := os.WriteFile(path, []byte(keys), 0600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newAuthenticator(authJWT, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func signedToken(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key interface{}) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	auth := newJWTAuthenticator(t)
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":        "team-a",
+			"businesses": []string{"b1", "b2"},
+			"exp":        now.Add(time.Hour).Unix(),
+		}
+	}
+	without := func(claim string) jwt.MapClaims {
+		claims := valid()
+		delete(claims, claim)
+		return claims
+	}
+	with := func(claim string, value interface{}) jwt.MapClaims {
+		claims := valid()
+		claims[claim] = value
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", signedToken(t, jwt.SigningMethodHS256, "k1", valid(), jwtSecret), true},
+		{"started", signedToken(t, jwt.SigningMethodHS256, "k1", with("nbf", now.Add(-time.Minute).Unix()), jwtSecret), true},
+		{"without exp", signedToken(t, jwt.SigningMethodHS256, "k1", without("exp"), jwtSecret), false},
+		{"expired", signedToken(t, jwt.SigningMethodHS256, "k1", with("exp", now.Add(-time.Minute).Unix()), jwtSecret), false},
+		{"not yet valid", signedToken(t, jwt.SigningMethodHS256, "k1", with("nbf", now.Add(time.Hour).Unix()), jwtSecret), false},
+		{"without sub", signedToken(t, jwt.SigningMethodHS256, "k1", without("sub"), jwtSecret), false},
+		{"unknown kid", signedToken(t, jwt.SigningMethodHS256, "k2", valid(), jwtSecret), false},
+		{"wrong secret", signedToken(t, jwt.SigningMethodHS256, "k1", valid(), []byte("another secret")), false},
+		{"unsigned", signedToken(t, jwt.SigningMethodNone, "k1", valid(), jwt.UnsafeAllowNoneSignatureType), false},
+		{"garbage", "not.a.token", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/reporting", nil)
+		r.Header.Set("Authorization", "Bearer "+tt.token)
+		caller, err := auth.Authenticate(r)
+		if !tt.ok {
+			if err != errBadCredential {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, errBadCredential)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if caller.Name != "team-a" || !caller.CanAccess("b2") || caller.CanAccess("b3") {
+			t.Errorf("%s: caller = %+v", tt.name, caller)
+		}
+	}
+}
+
+func TestJWTAuthenticatorWithoutToken(t *testing.T) {
+	auth := newJWTAuthenticator(t)
+	r := httptest.NewRequest("POST", "/reporting", nil)
+	if _, err := auth.Authenticate(r); err != errBadCredential {
+		t.Errorf("err = %v, want %v", err, errBadCredential)
+	}
+	if auth.Scheme() != "Bearer" {
+		t.Errorf("scheme = %s, want Bearer", auth.Scheme())
+	}
+}
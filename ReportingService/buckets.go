@@ -0,0 +1,74 @@
+package main
+
+import "time"
+
+// Bucketing time intervals accepted in
+// BusinessIDStartDateDaysAfterStartRequest.TimeInterval
+const (
+	intervalHour  = "hour"
+	intervalDay   = "day"
+	intervalWeek  = "week"
+	intervalMonth = "month"
+)
+
+func validInterval(interval string) bool {
+	switch interval {
+	case intervalHour, intervalDay, intervalWeek, intervalMonth:
+		return true
+	}
+	return false
+}
+
+// timeFrames splits [start, end) into consecutive buckets aligned on the
+// interval boundaries (top of the hour, midnight, Monday, first of the month)
+// of the wall clock in loc, so a day lasts 23 or 25 hours across a DST
+// transition. The first and last buckets are clipped to the requested range,
+// and every bucket is returned whether or not it holds any data.
+func timeFrames(start, end time.Time, interval string, loc *time.Location) []TimeFrame {
+	start, end = start.In(loc), end.In(loc)
+	frames := make([]TimeFrame, 0)
+	for from := start; from.Before(end); {
+		to := nextBoundary(from, interval)
+		if to.After(end) {
+			to = end
+		}
+		frames = append(frames, TimeFrame{Start: from, End: to})
+		from = to
+	}
+	return frames
+}
+
+// nextBoundary returns the first interval boundary strictly after t, in the
+// location of t.
+func nextBoundary(t time.Time, interval string) time.Time {
+	y, m, d := t.Date()
+	switch interval {
+	case intervalHour:
+		// Step back to the top of the hour in absolute time, as the wall
+		// clock hour is ambiguous when DST ends.
+		sinceTopOfHour := time.Duration(t.Minute())*time.Minute +
+			time.Duration(t.Second())*time.Second +
+			time.Duration(t.Nanosecond())
+		return t.Add(time.Hour - sinceTopOfHour)
+	case intervalWeek:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-daysSinceMonday+7, 0, 0, 0, 0, t.Location())
+	case intervalMonth:
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+// reportLocation returns the time zone a report is bucketed in: the one
+// requested, else the business' own, else UTC.
+func reportLocation(req ReportRequest) *time.Location {
+	if req.Location != nil {
+		return req.Location
+	}
+	if req.Data.Business.TimeZone != "" {
+		if loc, err := time.LoadLocation(req.Data.Business.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
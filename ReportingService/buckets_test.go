@@ -0,0 +1,256 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// frameStrings formats frames as start/end pairs in their own location.
+func frameStrings(frames []TimeFrame) []string {
+	s := make([]string, len(frames))
+	for i, tf := range frames {
+		s[i] = tf.Start.Format(time.RFC3339) + "/" + tf.End.Format(time.RFC3339)
+	}
+	return s
+}
+
+func TestTimeFrames(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		interval   string
+		want       []string
+	}{
+		{
+			name:     "hours clipped to the range",
+			start:    "2018-11-12T10:30:00Z",
+			end:      "2018-11-12T12:15:00Z",
+			interval: intervalHour,
+			want: []string{
+				"2018-11-12T10:30:00Z/2018-11-12T11:00:00Z",
+				"2018-11-12T11:00:00Z/2018-11-12T12:00:00Z",
+				"2018-11-12T12:00:00Z/2018-11-12T12:15:00Z",
+			},
+		},
+		{
+			name:     "days",
+			start:    "2018-11-12T03:04:05Z",
+			end:      "2018-11-14T00:00:00Z",
+			interval: intervalDay,
+			want: []string{
+				"2018-11-12T03:04:05Z/2018-11-13T00:00:00Z",
+				"2018-11-13T00:00:00Z/2018-11-14T00:00:00Z",
+			},
+		},
+		{
+			name:     "weeks start on Monday",
+			start:    "2018-11-08T00:00:00Z", // a Thursday
+			end:      "2018-11-22T00:00:00Z",
+			interval: intervalWeek,
+			want: []string{
+				"2018-11-08T00:00:00Z/2018-11-12T00:00:00Z",
+				"2018-11-12T00:00:00Z/2018-11-19T00:00:00Z",
+				"2018-11-19T00:00:00Z/2018-11-22T00:00:00Z",
+			},
+		},
+		{
+			name:     "week starting on a Monday",
+			start:    "2018-11-12T00:00:00Z",
+			end:      "2018-11-19T00:00:00Z",
+			interval: intervalWeek,
+			want: []string{
+				"2018-11-12T00:00:00Z/2018-11-19T00:00:00Z",
+			},
+		},
+		{
+			name:     "months",
+			start:    "2018-12-15T00:00:00Z",
+			end:      "2019-03-01T00:00:00Z",
+			interval: intervalMonth,
+			want: []string{
+				"2018-12-15T00:00:00Z/2019-01-01T00:00:00Z",
+				"2019-01-01T00:00:00Z/2019-02-01T00:00:00Z",
+				"2019-02-01T00:00:00Z/2019-03-01T00:00:00Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := frameStrings(timeFrames(at(tt.start), at(tt.end), tt.interval, time.UTC))
+		if !equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTimeFramesEmptyBuckets(t *testing.T) {
+	req := ReportRequest{
+		TimeInterval: intervalHour,
+		Start:        at("2018-11-12T00:00:00Z"),
+		End:          at("2018-11-13T00:00:00Z"),
+		Location:     time.UTC,
+	}
+	report := foodCostPercentage(req)
+	if len(report.Data) != 24 {
+		t.Fatalf("got %d buckets, want 24", len(report.Data))
+	}
+	for i, bucket := range report.Data {
+		if bucket.Value.String() != "0.00" {
+			t.Errorf("bucket %d = %s, want 0.00", i, bucket.Value)
+		}
+	}
+}
+
+func TestValidInterval(t *testing.T) {
+	for _, interval := range []string{intervalHour, intervalDay, intervalWeek, intervalMonth} {
+		if !validInterval(interval) {
+			t.Errorf("validInterval(%q) = false", interval)
+		}
+	}
+	for _, interval := range []string{"", "year", "Day"} {
+		if validInterval(interval) {
+			t.Errorf("validInterval(%q) = true", interval)
+		}
+	}
+}
+
+func TestTimeFramesAcrossDST(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name       string
+		start, end string
+		interval   string
+		hours      []float64
+	}{
+		{
+			name:     "DST ends: 25h day",
+			start:    "2018-11-03T00:00:00-07:00",
+			end:      "2018-11-06T00:00:00-08:00",
+			interval: intervalDay,
+			hours:    []float64{24, 25, 24},
+		},
+		{
+			name:     "DST starts: 23h day",
+			start:    "2018-03-10T00:00:00-08:00",
+			end:      "2018-03-13T00:00:00-07:00",
+			interval: intervalDay,
+			hours:    []float64{24, 23, 24},
+		},
+		{
+			name:     "DST ends: repeated 1am hour",
+			start:    "2018-11-04T00:00:00-07:00",
+			end:      "2018-11-04T03:00:00-08:00",
+			interval: intervalHour,
+			hours:    []float64{1, 1, 1, 1},
+		},
+		{
+			name:     "DST starts: skipped 2am hour",
+			start:    "2018-03-11T00:00:00-08:00",
+			end:      "2018-03-11T04:00:00-07:00",
+			interval: intervalHour,
+			hours:    []float64{1, 1, 1},
+		},
+		{
+			name:     "week of the DST end",
+			start:    "2018-10-29T00:00:00-07:00",
+			end:      "2018-11-12T00:00:00-08:00",
+			interval: intervalWeek,
+			hours:    []float64{169, 168},
+		},
+	}
+	for _, tt := range tests {
+		frames := timeFrames(at(tt.start), at(tt.end), tt.interval, la)
+		hours := make([]float64, len(frames))
+		for i, tf := range frames {
+			hours[i] = tf.End.Sub(tf.Start).Hours()
+			if tf.Start.Location() != la {
+				t.Errorf("%s: frame %d in %s, want %s", tt.name, i, tf.Start.Location(), la)
+			}
+		}
+		if len(hours) != len(tt.hours) {
+			t.Errorf("%s: hours = %v, want %v", tt.name, hours, tt.hours)
+			continue
+		}
+		for i := range hours {
+			if hours[i] != tt.hours[i] {
+				t.Errorf("%s: hours = %v, want %v", tt.name, hours, tt.hours)
+				break
+			}
+		}
+	}
+}
+
+func TestDaysStartAtLocalMidnight(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	frames := timeFrames(at("2018-11-04T00:00:00Z"), at("2018-11-06T00:00:00Z"), intervalDay, la)
+	want := []string{
+		"2018-11-03T17:00:00-07:00/2018-11-04T00:00:00-07:00",
+		"2018-11-04T00:00:00-07:00/2018-11-05T00:00:00-08:00",
+		"2018-11-05T00:00:00-08:00/2018-11-05T16:00:00-08:00",
+	}
+	if got := frameStrings(frames); !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReportLocation(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name string
+		req  ReportRequest
+		want string
+	}{
+		{"requested", ReportRequest{Location: ny, Data: POSData{Business: Business{TimeZone: la.String()}}}, ny.String()},
+		{"business", ReportRequest{Data: POSData{Business: Business{TimeZone: la.String()}}}, la.String()},
+		{"unknown business zone", ReportRequest{Data: POSData{Business: Business{TimeZone: "Mars/Olympus_Mons"}}}, "UTC"},
+		{"none", ReportRequest{}, "UTC"},
+	}
+	for _, tt := range tests {
+		if got := reportLocation(tt.req).String(); got != tt.want {
+			t.Errorf("%s: location = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLaborCostAcrossDST(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	req := ReportRequest{
+		TimeInterval: intervalDay,
+		Start:        at("2018-11-04T00:00:00-07:00"),
+		End:          at("2018-11-05T00:00:00-08:00"),
+		Location:     la,
+		Data: POSData{
+			// midnight to 3am local on the night DST ends is 4 hours
+			LaborEntries: []LaborEntry{
+				{ClockIn: at("2018-11-04T00:00:00-07:00"), ClockOut: at("2018-11-04T03:00:00-08:00"), PayRate: dec("10.00")},
+			},
+			OrderedItems: []OrderedItem{
+				{Price: dec("100.00"), CreatedAt: at("2018-11-04T12:00:00-08:00")},
+			},
+		},
+	}
+	report := laborCostPercentage(req)
+	if got, want := values(report), []string{"40.00"}; !equal(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+	if report.TimeZone != la.String() {
+		t.Errorf("time zone = %s, want %s", report.TimeZone, la)
+	}
+}
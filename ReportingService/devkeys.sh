@@ -0,0 +1,48 @@
+#!/bin/sh
+# Writes local development keys into the current directory, for -master-key
+# and -name-hash-key of ReportingService and mock-pos, and -auth-file of
+# ReportingService:
+#   master.key       keyring of the master keys business name data keys are wrapped with
+#   namehash.key     HMAC key of mock-pos business name hashes
+#   htpasswd         the posUser credential of -auth basic, on every business
+#   posUser.password its random password (htpasswd, from apache2-utils, makes the hash)
+# Files already there are kept, as a kept store only opens with its own keys.
+# Never use these keys outside development.
+set -e
+umask 077
+
+if [ -e master.key ]; then
+	echo "kept master.key"
+else
+	{
+		echo "# Local development keyring of ReportingService and mock-pos: key ID:base64 32 byte key lines, oldest first."
+		echo "dev-$(date +%Y-%m):$(head -c32 /dev/urandom | base64)"
+	} > master.key
+	echo "wrote master.key"
+fi
+
+if [ -e namehash.key ]; then
+	echo "kept namehash.key"
+else
+	{
+		echo "# Local development HMAC key of mock-pos business name hashes."
+		head -c32 /dev/urandom | base64
+	} > namehash.key
+	echo "wrote namehash.key"
+fi
+
+if [ -e htpasswd ]; then
+	echo "kept htpasswd"
+elif ! command -v htpasswd >/dev/null; then
+	echo "no htpasswd command (apache2-utils): skipped htpasswd" >&2
+else
+	password=$(head -c24 /dev/urandom | base64 | tr -d '/+=')
+	credential=$(htpasswd -nbB posUser "$password")
+	{
+		echo "# Local development credentials of ReportingService -auth basic: user:bcrypt hash:business IDs, * for every business"
+		echo "# Add users with \`htpasswd -nB <user>\`, revoke them by deleting their line."
+		echo "$credential:*"
+	} > htpasswd
+	echo "$password" > posUser.password
+	echo "wrote htpasswd and posUser.password"
+fi
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// The POS stores business names envelope encrypted: the name is encrypted
+// with AES-GCM under a per business data key, and the data key is wrapped
+// with AES-GCM under a master key of the keyring this service shares with the
+// POS.
+
+// dataKeySize is the size of master and data keys, selecting AES-256.
+const dataKeySize = 32
+
+var (
+	errBadKeyFile    = errors.New("keyring lines must be key ID:base64 32 byte key")
+	errUnknownKey    = errors.New("POS: business name encrypted under an unknown master key")
+	errUndecryptable = errors.New("POS: business name cannot be decrypted")
+)
+
+// keyring holds the master keys business data keys are wrapped with, read
+// from a file of "key ID:base64 key" lines. It is read again whenever it
+// changes, so a new POS master key is added before the POS starts wrapping
+// with it, and an old one is removed once the POS re-wrapped its records.
+type keyring struct {
+	keys *credentialsFile
+}
+
+func loadKeyring(path string) (keyring, error) {
+	file := &credentialsFile{path: path}
+	if err := file.load(); err != nil {
+		return keyring{}, err
+	}
+	k := keyring{file}
+	for id := range file.all() {
+		if _, ok := k.key(id); !ok {
+			return keyring{}, fmt.Errorf("%s: key %s: %v", path, id, errBadKeyFile)
+		}
+	}
+	return k, nil
+}
+
+func (k keyring) key(id string) ([]byte, bool) {
+	cred, ok := k.keys.lookup(id)
+	if !ok {
+		return nil, false
+	}
+	key, err := base64.StdEncoding.DecodeString(cred.secret)
+	if err != nil || len(key) != dataKeySize {
+		return nil, false
+	}
+	return key, true
+}
+
+// decryptBusiness unwraps the data key of b with the master key it names and
+// returns the business with its name decrypted.
+func (k keyring) decryptBusiness(b EncryptedBusiness) (Business, error) {
+	serviceKey, ok := k.key(b.ServiceKeyID)
+	if !ok {
+		return Business{}, errUnknownKey
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(b.EncryptedEnvelopeKey)
+	if err != nil {
+		return Business{}, errUndecryptable
+	}
+	dataKey, err := open(serviceKey, wrapped, []byte(b.EnvelopeKeyID))
+	if err != nil {
+		return Business{}, errUndecryptable
+	}
+	nonce, err := base64.StdEncoding.DecodeString(b.InitializationVector)
+	if err != nil {
+		return Business{}, errUndecryptable
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(b.Name)
+	if err != nil {
+		return Business{}, errUndecryptable
+	}
+	name, err := open(dataKey, append(nonce, ciphertext...), []byte(b.ID))
+	if err != nil {
+		return Business{}, errUndecryptable
+	}
+
+	business := b.Business
+	business.Name = string(name)
+	return business, nil
+}
+
+// open decrypts sealed, an AES-GCM nonce followed by the ciphertext, under
+// key.
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errUndecryptable
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+}
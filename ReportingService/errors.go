@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/sd/lb"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/sony/gobreaker"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// httpError is an error reported to the consumer with a specific HTTP status.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e httpError) Error() string {
+	return e.err.Error()
+}
+
+// errorResponse is the JSON envelope of a failed /reporting request.
+type errorResponse struct {
+	Status int    `json:"status"`
+	Err    string `json:"err"`
+}
+
+// errorStatus maps an error onto the HTTP status it is reported with.
+func errorStatus(err error) int {
+	switch e := err.(type) {
+	case httpError:
+		return e.status
+	case rateLimitedError:
+		return http.StatusTooManyRequests
+	case *url.Error:
+		// the POS request failed, e.g. because the report's context is done
+		return errorStatus(e.Err)
+	case lb.RetryError:
+		// every attempt at the POS failed, report why the last one did
+		if status := errorStatus(e.Final); status != http.StatusInternalServerError {
+			return status
+		}
+		return http.StatusBadGateway
+	}
+
+	switch err {
+	case errBadCredential:
+		return http.StatusUnauthorized
+	case errBadInput, errMissingParam, ErrEmpty, errUnknownReport:
+		return http.StatusBadRequest
+	case errForbidden:
+		return http.StatusForbidden
+	case errUnknownBusiness:
+		return http.StatusNotFound
+	case ratelimit.ErrLimited:
+		return http.StatusTooManyRequests
+	case gobreaker.ErrOpenState, gobreaker.ErrTooManyRequests, errTooManyPages, errUnknownKey, errUndecryptable:
+		return http.StatusBadGateway
+	case context.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// retryAfter returns the Retry-After seconds of a 429 answer: the wait until
+// the caller's next token, or a second when the POS is rate limited.
+func retryAfter(err error) int {
+	if e, ok := err.(rateLimitedError); ok {
+		return int(math.Ceil(e.retryAfter.Seconds()))
+	}
+	return 1
+}
+
+// makeErrorEncoder returns the httptransport.ServerErrorEncoder of the
+// /reporting endpoint. Unauthenticated callers are challenged with scheme,
+// rate limited ones told when to retry. Server side failures are logged, and
+// answered with the status text only, so the POS and its failures stay
+// hidden from consumers.
+func makeErrorEncoder(scheme string, logger log.Logger) httptransport.ErrorEncoder {
+	return func(_ context.Context, err error, w http.ResponseWriter) {
+		status := errorStatus(err)
+		message := err.Error()
+		if status >= http.StatusInternalServerError {
+			logger.Log("status", status, "err", err)
+			message = http.StatusText(status)
+		}
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", scheme+` realm="reporting"`)
+		}
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter(err)))
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorResponse{Status: status, Err: message})
+	}
+}
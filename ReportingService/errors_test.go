@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/sd/lb"
+	"github.com/sony/gobreaker"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorStatus(t *testing.T) {
+	posFailure := posStatusError{"/checks", http.StatusInternalServerError, "500 Internal Server Error: boom"}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"bad credential", errBadCredential, http.StatusUnauthorized},
+		{"bad input", errBadInput, http.StatusBadRequest},
+		{"missing param", errMissingParam, http.StatusBadRequest},
+		{"empty", ErrEmpty, http.StatusBadRequest},
+		{"unknown report", errUnknownReport, http.StatusBadRequest},
+		{"undecodable body", httpError{http.StatusBadRequest, errors.New("EOF")}, http.StatusBadRequest},
+		{"forbidden", errForbidden, http.StatusForbidden},
+		{"unknown business", errUnknownBusiness, http.StatusNotFound},
+		{"caller rate limited", rateLimitedError{"caller", time.Second}, http.StatusTooManyRequests},
+		{"POS rate limited", ratelimit.ErrLimited, http.StatusTooManyRequests},
+		{"breaker open", gobreaker.ErrOpenState, http.StatusBadGateway},
+		{"breaker half open", gobreaker.ErrTooManyRequests, http.StatusBadGateway},
+		{"too many pages", errTooManyPages, http.StatusBadGateway},
+		{"unknown key", errUnknownKey, http.StatusBadGateway},
+		{"undecryptable", errUndecryptable, http.StatusBadGateway},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"POS request deadline", &url.Error{Op: "Get", URL: "http://pos", Err: context.DeadlineExceeded}, http.StatusGatewayTimeout},
+		{"unexpected", errors.New("boom"), http.StatusInternalServerError},
+		{"POS failure", posFailure, http.StatusInternalServerError},
+		{"retries failed", lb.RetryError{Final: posFailure}, http.StatusBadGateway},
+		{"retries timed out", lb.RetryError{Final: context.DeadlineExceeded}, http.StatusGatewayTimeout},
+		{"retries rate limited", lb.RetryError{Final: ratelimit.ErrLimited}, http.StatusTooManyRequests},
+		{"retries breaker open", lb.RetryError{Final: gobreaker.ErrOpenState}, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		if got := errorStatus(tt.err); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestErrorEncoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   string
+		header string
+		value  string
+		logged bool
+	}{
+		{"challenge", errBadCredential, http.StatusUnauthorized, errBadCredential.Error(), "WWW-Authenticate", `Basic realm="reporting"`, false},
+		{"retry after", rateLimitedError{"caller", 1500 * time.Millisecond}, http.StatusTooManyRequests, "client: caller rate limit exceeded, retry after 1.5s", "Retry-After", "2", false},
+		{"POS rate limited", ratelimit.ErrLimited, http.StatusTooManyRequests, ratelimit.ErrLimited.Error(), "Retry-After", "1", false},
+		{"client error", errUnknownBusiness, http.StatusNotFound, errUnknownBusiness.Error(), "", "", false},
+		{"POS failure hidden", lb.RetryError{Final: errors.New("dial tcp 10.0.0.1:8091: connection refused")}, http.StatusBadGateway, "Bad Gateway", "", "", true},
+		{"timeout hidden", context.DeadlineExceeded, http.StatusGatewayTimeout, "Gateway Timeout", "", "", true},
+		{"internal error hidden", errors.New("boom"), http.StatusInternalServerError, "Internal Server Error", "", "", true},
+	}
+	for _, tt := range tests {
+		var logged bytes.Buffer
+		w := httptest.NewRecorder()
+		makeErrorEncoder("Basic", log.NewLogfmtLogger(&logged))(context.Background(), tt.err, w)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		var response errorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if response.Status != tt.status || response.Err != tt.body {
+			t.Errorf("%s: body = %+v, want %d %q", tt.name, response, tt.status, tt.body)
+		}
+		if tt.header != "" && w.Header().Get(tt.header) != tt.value {
+			t.Errorf("%s: %s = %q, want %q", tt.name, tt.header, w.Header().Get(tt.header), tt.value)
+		}
+		if got := strings.Contains(logged.String(), tt.err.Error()); got != tt.logged {
+			t.Errorf("%s: logged %q", tt.name, logged.String())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/go-kit/kit/ratelimit"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const metricsNamespace = "reporting"
+
+// instrumentingMiddleware counts the /reporting requests and observes the
+// latency of the reports, by report type and the HTTP status they are
+// answered with. Requests are counted by countRequest, at the transport
+// layer, so those rejected before reaching the service are counted too.
+type instrumentingMiddleware struct {
+	requestCount   metrics.Counter
+	requestLatency metrics.Histogram
+	next           ReportingService
+}
+
+func newInstrumentingMiddleware(next ReportingService) instrumentingMiddleware {
+	fieldKeys := []string{"report_type", "status"}
+	return instrumentingMiddleware{
+		requestCount: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Number of /reporting requests, the report type left empty for those rejected before it was known.",
+		}, fieldKeys),
+		requestLatency: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time to fetch the POS data of and calculate a report.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, fieldKeys),
+		next: next,
+	}
+}
+
+func (mw instrumentingMiddleware) reporting(ctx context.Context, req ReportRequest) (output Report, err error) {
+	if labels, ok := ctx.Value(requestLabelsKey).(*requestLabels); ok {
+		labels.reportType = req.ReportType
+	}
+	defer func(begin time.Time) {
+		status := "200"
+		if err != nil {
+			status = strconv.Itoa(errorStatus(err))
+		}
+		mw.requestLatency.With("report_type", req.ReportType, "status", status).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	output, err = mw.next.reporting(ctx, req)
+	return
+}
+
+// requestLabels are the labels of a /reporting request learnt once it
+// reaches the service, passed back to countRequest through the context.
+type requestLabels struct {
+	reportType string
+}
+
+// labelsToContext is the httptransport.ServerBefore making room for the
+// requestLabels of the request.
+func labelsToContext(ctx context.Context, _ *http.Request) context.Context {
+	return context.WithValue(ctx, requestLabelsKey, &requestLabels{})
+}
+
+// countRequest is the httptransport.ServerFinalizer counting every /reporting
+// request by the status it was answered with, including those the decoder
+// rejected, such as unauthenticated (401) or malformed (400) ones.
+func (mw instrumentingMiddleware) countRequest(ctx context.Context, code int, _ *http.Request) {
+	var reportType string
+	if labels, ok := ctx.Value(requestLabelsKey).(*requestLabels); ok {
+		reportType = labels.reportType
+	}
+	mw.requestCount.With("report_type", reportType, "status", strconv.Itoa(code)).Add(1)
+}
+
+// posMetrics instrument the POS proxy: the requests sent to each instance,
+// their circuit breakers and rate limiters, and the retries of the
+// load balancer.
+type posMetrics struct {
+	requestLatency metrics.Histogram
+	breakerState   metrics.Gauge
+	rateLimited    metrics.Counter
+	retries        metrics.Counter
+}
+
+func newPOSMetrics() posMetrics {
+	return posMetrics{
+		requestLatency: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "pos",
+			Name:      "request_duration_seconds",
+			Help:      "Time of the requests sent to a POS instance, by outcome.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, []string{"instance", "path", "success"}),
+		breakerState: kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "pos",
+			Name:      "circuit_breaker_state",
+			Help:      "Circuit breaker state of a POS instance: 0 closed, 1 half-open, 2 open.",
+		}, []string{"instance"}),
+		rateLimited: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "pos",
+			Name:      "rate_limited_total",
+			Help:      "Number of POS requests to an instance rejected by its or the upstream rate limiter.",
+		}, []string{"instance"}),
+		retries: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "pos",
+			Name:      "retries_total",
+			Help:      "Number of failed POS requests retried by the load balancer.",
+		}, nil),
+	}
+}
+
+// watchBreaker returns settings reporting the state changes of the circuit
+// breaker they configure.
+func (m posMetrics) watchBreaker(settings gobreaker.Settings) gobreaker.Settings {
+	m.breakerState.With("instance", settings.Name).Set(float64(gobreaker.StateClosed))
+	settings.OnStateChange = func(name string, _, to gobreaker.State) {
+		m.breakerState.With("instance", name).Set(float64(to))
+	}
+	return settings
+}
+
+// instrument observes the requests sent to instance, including those its
+// circuit breaker or rate limiter rejected.
+func (m posMetrics) instrument(instance string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			defer func(begin time.Time) {
+				if err == ratelimit.ErrLimited {
+					m.rateLimited.With("instance", instance).Add(1)
+				}
+				m.requestLatency.With(
+					"instance", instance,
+					"path", request.(posRequest).Path,
+					"success", strconv.FormatBool(err == nil),
+				).Observe(time.Since(begin).Seconds())
+			}(time.Now())
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// retried counts a retry of a failed POS request.
+func (m posMetrics) retried() {
+	m.retries.Add(1)
+}
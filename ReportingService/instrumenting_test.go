@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCounter adds up its counts by label values, joined with commas.
+type fakeCounter struct {
+	counts map[string]float64
+	lvs    []string
+}
+
+func (c fakeCounter) With(labelValues ...string) metrics.Counter {
+	return fakeCounter{c.counts, append(append([]string(nil), c.lvs...), labelValues...)}
+}
+
+func (c fakeCounter) Add(delta float64) {
+	c.counts[strings.Join(c.lvs, ",")] += delta
+}
+
+// fakeAuthenticator lets through the "Bearer good" callers.
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) Authenticate(r *http.Request) (Caller, error) {
+	if r.Header.Get("Authorization") != "Bearer good" {
+		return Caller{}, errBadCredential
+	}
+	return Caller{Name: "good", Businesses: []string{"b1", "unknown"}}, nil
+}
+
+func (fakeAuthenticator) Scheme() string { return "Bearer" }
+
+// fakeReports answers every report, but the ones on unknown businesses.
+type fakeReports struct{}
+
+func (fakeReports) reporting(_ context.Context, req ReportRequest) (Report, error) {
+	if req.BusinessID == "unknown" {
+		return Report{}, errUnknownBusiness
+	}
+	return Report{BusinessID: req.BusinessID, Report: req.ReportType}, nil
+}
+
+func TestRequestsCountedAtTransport(t *testing.T) {
+	counts := make(map[string]float64)
+	var svc ReportingService = fakeReports{}
+	svc = authorizingMiddleware{svc}
+	instrumenting := instrumentingMiddleware{
+		requestCount:   fakeCounter{counts: counts},
+		requestLatency: discard.NewHistogram(),
+		next:           svc,
+	}
+	handler := httptransport.NewServer(
+		applyRequestTimeout(makePOSEndpoint(instrumenting)),
+		decodeBusinessIDStartDateDaysAfterStartRequest(fakeAuthenticator{}, 24*time.Hour),
+		encodeResponse,
+		httptransport.ServerErrorEncoder(makeErrorEncoder("Bearer", log.NewNopLogger())),
+		httptransport.ServerBefore(requestTimeoutToContext, labelsToContext),
+		httptransport.ServerFinalizer(instrumenting.countRequest),
+	)
+
+	const valid = `{"business_id":"b1","start":"2018-11-12T00:00:00Z","end":"2018-11-13T00:00:00Z","report_type":"FCP"}`
+	requests := []struct {
+		auth, timeout, body string
+	}{
+		{"good", "", valid},
+		{"good", "", valid},
+		{"", "", valid},
+		{"bad", "", valid},
+		{"good", "", `{"business_id":`},
+		{"good", "", `{"business_id":"b1","report_type":"XYZ"}`},
+		{"good", "soon", valid},
+		{"good", "", strings.Replace(valid, `"b1"`, `"b2"`, 1)},
+		{"good", "", strings.Replace(valid, `"b1"`, `"unknown"`, 1)},
+	}
+	for _, req := range requests {
+		r := httptest.NewRequest("POST", "/reporting", strings.NewReader(req.body))
+		if req.auth != "" {
+			r.Header.Set("Authorization", "Bearer "+req.auth)
+		}
+		if req.timeout != "" {
+			r.Header.Set(requestTimeoutHeader, req.timeout)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	want := map[string]float64{
+		"report_type,FCP,status,200": 2,
+		"report_type,,status,401":    2,
+		"report_type,,status,400":    3,
+		"report_type,FCP,status,403": 1,
+		"report_type,FCP,status,404": 1,
+	}
+	if len(counts) != len(want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+	for labels, n := range want {
+		if counts[labels] != n {
+			t.Errorf("%s: count = %v, want %v", labels, counts[labels], n)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package tracing sets up the OpenTelemetry tracing of ReportingService and
+// mock-pos, and traces the HTTP requests they serve.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// Trace exporters selectable with the -trace flag
+const (
+	None   = "none"
+	Stdout = "stdout" // pretty printed JSON spans on stdout, for tests
+	OTLP   = "otlp"   // OTLP over HTTP, to OTEL_EXPORTER_OTLP_ENDPOINT (default localhost:4318)
+)
+
+// Setup installs the global tracer provider exporting the spans of service
+// to exporter, and the W3C traceparent propagator, whether spans are exported
+// or not.
+func Setup(service, exporter string) error {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var (
+		spans sdktrace.SpanExporter
+		err   error
+	)
+	switch exporter {
+	case None:
+		return nil
+	case Stdout:
+		spans, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case OTLP:
+		spans, err = otlptracehttp.New(context.Background())
+	default:
+		return fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return err
+	}
+
+	processor := sdktrace.NewBatchSpanProcessor(spans)
+	if exporter == Stdout {
+		processor = sdktrace.NewSimpleSpanProcessor(spans)
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	))
+	return nil
+}
+
+// StartServerSpan returns the httptransport.ServerBefore starting the span of
+// an incoming request with tracer, continuing the trace of its traceparent
+// header.
+func StartServerSpan(tracer trace.Tracer) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+		attributes := []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+		}
+		if r.URL.RawQuery != "" {
+			attributes = append(attributes, semconv.URLQuery(r.URL.RawQuery))
+		}
+		ctx, _ = tracer.Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attributes...),
+		)
+		return ctx
+	}
+}
+
+// EndServerSpan is the httptransport.ServerFinalizer ending the span started
+// by StartServerSpan.
+func EndServerSpan(ctx context.Context, code int, _ *http.Request) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(code))
+	if code >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(code))
+	}
+	span.End()
+}
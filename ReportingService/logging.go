@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/go-kit/kit/log"
 	"time"
 )
@@ -12,22 +13,28 @@ type loggingMiddleware struct {
 	next   ReportingService
 }
 
-func (mw loggingMiddleware) reporting (req BusinessesRequest) (output Business, err error) {
+func (mw loggingMiddleware) reporting (ctx context.Context, req ReportRequest) (output Report, err error) {
 	defer func(begin time.Time) {
 		callTime := time.Now().Format(TimeFormat)
 		mw.logger.Log(
 			"callTime", callTime,
 			"method", "reporting",
-			"Limit", req.Limit,
-			"Offset", req.Offset,
+			"Caller", req.Caller.Name,
 			"BusinessID", req.BusinessID,
-			"output", output,
+			"ReportType", req.ReportType,
+			"TimeInterval", req.TimeInterval,
+			"Start", req.Start.Format(TimeFormat),
+			"End", req.End.Format(TimeFormat),
+			"Employees", len(req.Data.Employees),
+			"LaborEntries", len(req.Data.LaborEntries),
+			"OrderedItems", len(req.Data.OrderedItems),
+			"Buckets", len(output.Data),
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
-	output, err = mw.next.reporting(req)
+	output, err = mw.next.reporting(ctx, req)
 	return
 }
 
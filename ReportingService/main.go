@@ -1,18 +1,40 @@
 package main
 
 import (
-	"context"
 	"flag"
 	"github.com/go-kit/kit/log"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"net/http"
 	"os"
+	"reportingservice/internal/tracing"
+	"time"
 )
 
 func main() {
 	var (
 		listen= flag.String("listen", ":8090", "HTTP listen address")
 		proxy= flag.String("proxy", ":8091", "Comma-separated list of URLs to proxy POS APIs requests")
+		maxPages= flag.Int("pos-max-pages", 200, "Most pages fetched per POS collection before a report gives up")
+		qps= flag.Int("pos-qps", 100, "POS requests per second per instance beyond which they are rejected, 0 for no limit")
+		upstreamQPS= flag.Int("pos-upstream-qps", 200, "POS requests per second to all instances together beyond which they are rejected, 0 for no limit")
+		maxAttempts= flag.Int("pos-max-attempts", 3, "Attempts per POS request, on any instance, before giving up")
+		maxTime= flag.Duration("pos-max-time", 250*time.Millisecond, "Wallclock time of the attempts of a POS request before giving up")
+		backoff= flag.Duration("pos-backoff", 25*time.Millisecond, "Wait before retrying a POS request, randomized and doubled for each retry")
+		maxBackoff= flag.Duration("pos-max-backoff", 100*time.Millisecond, "Longest wait between retries of a POS request")
+		breakerFailures= flag.Uint("pos-breaker-failures", 5, "Consecutive failures of a POS instance opening its circuit breaker")
+		breakerTimeout= flag.Duration("pos-breaker-timeout", 60*time.Second, "Time a POS instance circuit breaker stays open before letting probes through")
+		breakerProbes= flag.Uint("pos-breaker-probes", 1, "Requests let through a half-open POS instance circuit breaker; it closes if they all succeed")
+		resilienceFile= flag.String("pos-resilience", "", "JSON file overriding the POS resilience flags, per POS instance if need be")
+		callerRate= flag.Float64("caller-rate", 5, "Reports per second each credential may request on average, 0 for no limit")
+		callerBurst= flag.Int("caller-burst", 20, "Reports each credential may request in a burst")
+		businessRate= flag.Float64("business-rate", 10, "Reports per second on each business on average, whoever requests them, 0 for no limit")
+		businessBurst= flag.Int("business-burst", 40, "Reports on each business in a burst")
+		maxRange= flag.Duration("max-range", 366*24*time.Hour, "Longest date range a report may cover")
+		authMethod= flag.String("auth", authBasic, "Authentication of callers: basic (htpasswd bcrypt file), apikey (Bearer API keys file) or jwt (Bearer HMAC-signed JWTs, key set file)")
+		authFile= flag.String("auth-file", "", "Credentials file of the -auth method (required; devkeys.sh makes a development htpasswd)")
+		traceExporter= flag.String("trace", tracing.None, "Trace exporter: none, stdout (spans printed as JSON) or otlp (OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT, default localhost:4318)")
+		keyringFile= flag.String("master-key", "", "Keyring file of the master keys the POS wraps business name data keys with (required; devkeys.sh makes development ones)")
 	)
 	flag.Parse()
 
@@ -20,18 +42,76 @@ func main() {
 	logger = log.NewLogfmtLogger(os.Stderr)
 	logger = log.With(logger, "listen", *listen, "caller", log.DefaultCaller)
 
+	if *authFile == "" {
+		logger.Log("err", "-auth-file is required")
+		os.Exit(1)
+	}
+	auth, err := newAuthenticator(*authMethod, *authFile)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+
+	if err := tracing.Setup("ReportingService", *traceExporter); err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+
+	if *keyringFile == "" {
+		logger.Log("err", "-master-key is required")
+		os.Exit(1)
+	}
+	keys, err := loadKeyring(*keyringFile)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+
+	policy := resiliencePolicy{
+		UpstreamQPS: *upstreamQPS,
+		Retry: retryPolicy{
+			MaxAttempts: *maxAttempts,
+			MaxTime:     duration(*maxTime),
+			Backoff:     duration(*backoff),
+			MaxBackoff:  duration(*maxBackoff),
+		},
+		Default: instancePolicy{
+			QPS: *qps,
+			Breaker: breakerPolicy{
+				ConsecutiveFailures: uint32(*breakerFailures),
+				OpenTimeout:         duration(*breakerTimeout),
+				HalfOpenProbes:      uint32(*breakerProbes),
+			},
+		},
+	}
+	if *resilienceFile != "" {
+		if policy, err = loadResiliencePolicy(*resilienceFile, policy); err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+	}
+
 	var svc ReportingService
 	svc = reportingService{}
+	svc = tracingMiddleware{svc}
 	svc = loggingMiddleware{logger, svc}
-	svc = proxyingMiddleware(context.Background(), *proxy, logger)(svc)
+	svc = proxyingMiddleware(*proxy, policy, *maxPages, keys, newPOSMetrics(), logger)(svc)
+	svc = newLimitingMiddleware(bucketPolicy{*callerRate, *callerBurst}, bucketPolicy{*businessRate, *businessBurst})(svc)
+	svc = authorizingMiddleware{svc}
+	instrumenting := newInstrumentingMiddleware(svc)
+	svc = instrumenting
 
 	posHandler := httptransport.NewServer(
-		makePOSEndpoint(svc),
-		decodeBusinessIDStartDateDaysAfterStartRequest,
+		applyRequestTimeout(makePOSEndpoint(svc)),
+		decodeBusinessIDStartDateDaysAfterStartRequest(auth, *maxRange),
 		encodeResponse,
+		httptransport.ServerErrorEncoder(makeErrorEncoder(auth.Scheme(), logger)),
+		httptransport.ServerBefore(tracing.StartServerSpan(tracer), requestTimeoutToContext, labelsToContext),
+		httptransport.ServerFinalizer(tracing.EndServerSpan, instrumenting.countRequest),
 	)
 
 	http.Handle("/reporting", posHandler)
+	http.Handle("/metrics", promhttp.Handler())
 	logger.Log("msg", "HTTP", "addr", *listen)
 	logger.Log("err", http.ListenAndServe(*listen, nil))
 }
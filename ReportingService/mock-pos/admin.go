@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/go-kit/kit/endpoint"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	errRecordWithoutID = errors.New("snapshot record without id")
+	errUnknownKey      = errors.New("snapshot business data key wrapped with a master key not in the keyring")
+)
+
+// adminAuth lets through to next the requests bearing token, as in
+// Authorization: Bearer {token}, and refuses the others with 401.
+type adminAuth struct {
+	token string
+	next  http.Handler
+}
+
+func (a adminAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) ||
+		subtle.ConstantTimeCompare([]byte(header[len(prefix):]), []byte(a.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mock-pos admin"`)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("admin token required"))
+		return
+	}
+	a.next.ServeHTTP(w, r)
+}
+
+// RewrapResult reports a re-wrap of the business data keys.
+type RewrapResult struct {
+	ServiceKeyID string `json:"service_key_id"` // the current master key
+	Rewrapped    int    `json:"rewrapped"`      // data keys moved onto it
+}
+
+// RewrapBusinesses wraps every business data key still wrapped with an older
+// master key with the current one. Run it after a new key was appended to the
+// keyring, and remove the old key once it returns; it is safe to run again.
+func (s mockPOSService) RewrapBusinesses() (RewrapResult, error) {
+	var result RewrapResult
+	result.ServiceKeyID, _ = s.envelope.keys.currentKey()
+	businesses, err := s.store.GetAllBusinesses()
+	if err != nil {
+		return result, err
+	}
+	for _, b := range businesses {
+		rewrapped, changed, err := s.envelope.rewrapBusiness(b)
+		if err != nil {
+			return result, err
+		}
+		if changed {
+			if _, err := s.store.UpdateBusinessByID(rewrapped, rewrapped.ID); err != nil {
+				return result, err
+			}
+			result.Rewrapped++
+		}
+	}
+	return result, nil
+}
+
+// Snapshot is the whole dataset, as served by /admin/snapshot and loaded by
+// /admin/restore. Businesses keep their names encrypted, so a snapshot only
+// restores where the keyring holds the master keys their data keys are
+// wrapped with, and the name hash key is the same.
+type Snapshot struct {
+	Businesses   []EncryptedBusiness `json:"businesses"`
+	Checks       []Check             `json:"checks"`
+	Employees    []Employee          `json:"employees"`
+	LaborEntries []LaborEntry        `json:"labor_entries"`
+	MenuItems    []MenuItem          `json:"menu_items"`
+	OrderedItems []OrderedItem       `json:"ordered_items"`
+}
+
+// Snapshot returns every record, at one point in time.
+func (s mockPOSService) Snapshot() (Snapshot, error) {
+	return s.store.Snapshot()
+}
+
+// Restore replaces every record with those of snapshot, IDs included, once it
+// checked they all have an ID and the business they name is in it.
+func (s mockPOSService) Restore(snapshot Snapshot) error {
+	known := make(map[string]bool, len(snapshot.Businesses))
+	for _, b := range snapshot.Businesses {
+		if b.ID == "" {
+			return errRecordWithoutID
+		}
+		if _, ok := s.envelope.keys.key(b.ServiceKeyID); !ok {
+			return errUnknownKey
+		}
+		known[b.ID] = true
+	}
+	check := func(id, businessID string) error {
+		if id == "" {
+			return errRecordWithoutID
+		}
+		if !known[businessID] {
+			return errUnknownBusiness
+		}
+		return nil
+	}
+	for _, c := range snapshot.Checks {
+		if err := check(c.ID, c.BusinessID); err != nil {
+			return err
+		}
+	}
+	for _, e := range snapshot.Employees {
+		if err := check(e.ID, e.BusinessID); err != nil {
+			return err
+		}
+	}
+	for _, l := range snapshot.LaborEntries {
+		if err := check(l.ID, l.BusinessID); err != nil {
+			return err
+		}
+	}
+	for _, m := range snapshot.MenuItems {
+		if err := check(m.ID, m.BusinessID); err != nil {
+			return err
+		}
+	}
+	for _, o := range snapshot.OrderedItems {
+		if err := check(o.ID, o.BusinessID); err != nil {
+			return err
+		}
+	}
+	return s.store.Restore(snapshot)
+}
+
+func makeSnapshotEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, _ interface{}) (interface{}, error) {
+		return svc.Snapshot()
+	}
+}
+
+func makeRestoreEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return struct{}{}, svc.Restore(request.(Snapshot))
+	}
+}
+
+func decodeSnapshotRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request Snapshot
+	if err := decodeBody(r, &request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func makeRewrapBusinessesEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, _ interface{}) (interface{}, error) {
+		return svc.RewrapBusinesses()
+	}
+}
+
+func decodeEmptyRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return struct{}{}, nil
+}
+
+func (mw loggingMiddleware) RewrapBusinesses() (output RewrapResult, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"callTime", time.Now().Format(TimeFormat),
+			"method", "rewrapBusinesses",
+			"serviceKeyID", output.ServiceKeyID,
+			"rewrapped", output.Rewrapped,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.RewrapBusinesses()
+	return
+}
+
+func (mw loggingMiddleware) Snapshot() (output Snapshot, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"callTime", time.Now().Format(TimeFormat),
+			"method", "snapshot",
+			"businesses", len(output.Businesses),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.Snapshot()
+	return
+}
+
+func (mw loggingMiddleware) Restore(snapshot Snapshot) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"callTime", time.Now().Format(TimeFormat),
+			"method", "restore",
+			"businesses", len(snapshot.Businesses),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	err = mw.next.Restore(snapshot)
+	return
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	served := false
+	h := adminAuth{"s3cret", http.HandlerFunc(func(http.ResponseWriter, *http.Request) { served = true })}
+
+	tests := []struct {
+		auth string
+		want int
+	}{
+		{"Bearer s3cret", http.StatusOK},
+		{"", http.StatusUnauthorized},
+		{"Bearer", http.StatusUnauthorized},
+		{"Bearer ", http.StatusUnauthorized},
+		{"Bearer s3cre", http.StatusUnauthorized},
+		{"Bearer s3cret2", http.StatusUnauthorized},
+		{"Basic s3cret", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		served = false
+		r := httptest.NewRequest("GET", "/admin/snapshot", nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want || served != (tt.want == http.StatusOK) {
+			t.Errorf("%q: status %d, served %v, want %d", tt.auth, w.Code, served, tt.want)
+		}
+		if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%q: no WWW-Authenticate", tt.auth)
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// boltCollection names the buckets of a collection in a boltStore: its JSON
+// records by ID, and its business index, whose keys are the business ID and
+// record ID separated by a zero byte.
+type boltCollection struct {
+	records    []byte
+	byBusiness []byte // nil for businesses
+}
+
+var (
+	boltBusinesses   = boltCollection{[]byte("businesses"), nil}
+	boltChecks       = boltCollection{[]byte("checks"), []byte("checks_by_business")}
+	boltEmployees    = boltCollection{[]byte("employees"), []byte("employees_by_business")}
+	boltLaborEntries = boltCollection{[]byte("labor_entries"), []byte("labor_entries_by_business")}
+	boltMenuItems    = boltCollection{[]byte("menu_items"), []byte("menu_items_by_business")}
+	boltOrderedItems = boltCollection{[]byte("ordered_items"), []byte("ordered_items_by_business")}
+
+	boltCollections = []boltCollection{boltBusinesses, boltChecks, boltEmployees, boltLaborEntries, boltMenuItems, boltOrderedItems}
+)
+
+// boltStore is the Storage of an embedded bbolt file, which keeps the records
+// and their IDs across restarts. Lists are in ID order, and read no further
+// than the end of their page.
+type boltStore struct {
+	db *bolt.DB
+}
+
+// openBoltStore opens, or creates, the store of the file at path. The file
+// is locked while open, so a second mock-pos fails to open it after a second.
+func openBoltStore(path string) (boltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return boltStore{}, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return createBuckets(tx)
+	})
+	if err != nil {
+		db.Close()
+		return boltStore{}, err
+	}
+	return boltStore{db}, nil
+}
+
+func createBuckets(tx *bolt.Tx) error {
+	for _, c := range boltCollections {
+		if _, err := tx.CreateBucketIfNotExists(c.records); err != nil {
+			return err
+		}
+		if c.byBusiness != nil {
+			if _, err := tx.CreateBucketIfNotExists(c.byBusiness); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func indexKey(businessID, id string) []byte {
+	return []byte(businessID + "\x00" + id)
+}
+
+// put stores record under id, indexed by businessID. A new record fails with
+// errExists if the ID is taken, an update with errNotFound if it is not.
+func put(tx *bolt.Tx, c boltCollection, id, businessID string, record interface{}, create bool) error {
+	records := tx.Bucket(c.records)
+	old := records.Get([]byte(id))
+	if create && old != nil {
+		return errExists
+	}
+	if !create && old == nil {
+		return errNotFound
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if c.byBusiness != nil {
+		if old != nil {
+			var indexed struct {
+				BusinessID string `json:"business_id"`
+			}
+			if err := json.Unmarshal(old, &indexed); err != nil {
+				return err
+			}
+			if err := tx.Bucket(c.byBusiness).Delete(indexKey(indexed.BusinessID, id)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(c.byBusiness).Put(indexKey(businessID, id), nil); err != nil {
+			return err
+		}
+	}
+	return records.Put([]byte(id), value)
+}
+
+func (s boltStore) put(c boltCollection, id, businessID string, record interface{}, create bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, c, id, businessID, record, create)
+	})
+}
+
+// remove deletes the record of id, or fails with errNotFound.
+func (s boltStore) remove(c boltCollection, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(c.records)
+		old := records.Get([]byte(id))
+		if old == nil {
+			return errNotFound
+		}
+		var indexed struct {
+			BusinessID string `json:"business_id"`
+		}
+		if err := json.Unmarshal(old, &indexed); err != nil {
+			return err
+		}
+		if err := tx.Bucket(c.byBusiness).Delete(indexKey(indexed.BusinessID, id)); err != nil {
+			return err
+		}
+		return records.Delete([]byte(id))
+	})
+}
+
+// get decodes the record of id into record, which is left as is if there is
+// none.
+func (s boltStore) get(c boltCollection, id string, record interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		if value := tx.Bucket(c.records).Get([]byte(id)); value != nil {
+			return json.Unmarshal(value, record)
+		}
+		return nil
+	})
+}
+
+// each calls f with every record of the collection, in ID order.
+func each(tx *bolt.Tx, c boltCollection, f func(value []byte) error) error {
+	return tx.Bucket(c.records).ForEach(func(_, value []byte) error {
+		return f(value)
+	})
+}
+
+// eachOfBusiness calls f with the records of businessID, in ID order.
+func eachOfBusiness(tx *bolt.Tx, c boltCollection, businessID string, f func(value []byte) error) error {
+	records := tx.Bucket(c.records)
+	prefix := indexKey(businessID, "")
+	cursor := tx.Bucket(c.byBusiness).Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		if err := f(records.Get(k[len(prefix):])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachOf calls f with the records of businessID, or with every record if it
+// is empty, in ID order.
+func eachOf(tx *bolt.Tx, c boltCollection, businessID string, f func(value []byte) error) error {
+	if businessID == "" {
+		return each(tx, c, f)
+	}
+	return eachOfBusiness(tx, c, businessID, f)
+}
+
+// errPageFull ends the walk of eachOnPage once the page is full.
+var errPageFull = errors.New("page full")
+
+// filtered holds the fields of a record the filters of a list look at. Only
+// labor entries have a clock in and out, other records always being on shift.
+type filtered struct {
+	BusinessID string    `json:"business_id"`
+	ClockIn    time.Time `json:"clock_in"`
+	ClockOut   time.Time `json:"clock_out"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// eachOnPage calls f with the records of the page of filter, in ID order.
+// Records are only decoded to check the date filters, if any, and the walk
+// ends with the page.
+func eachOnPage(tx *bolt.Tx, c boltCollection, filter BusinessesRequest, f func(value []byte) error) error {
+	dated := !filter.UpdatedStart.IsZero() || !filter.UpdatedEnd.IsZero() ||
+		!filter.CreatedStart.IsZero() || !filter.CreatedEnd.IsZero() ||
+		!filter.ClockedStart.IsZero() || !filter.ClockedEnd.IsZero()
+	p := newPager(filter)
+	err := eachOf(tx, c, filter.BusinessID, func(value []byte) error {
+		if p.full() {
+			return errPageFull
+		}
+		if dated {
+			var record filtered
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if !filter.matches(record.BusinessID, record.UpdatedAt, record.CreatedAt) ||
+				!filter.onShift(record.ClockIn, record.ClockOut) {
+				return nil
+			}
+		}
+		if !p.take() {
+			return nil
+		}
+		return f(value)
+	})
+	if err == errPageFull {
+		return nil
+	}
+	return err
+}
+
+func (s boltStore) CreateBusiness(b EncryptedBusiness) (string, error) {
+	return b.ID, s.put(boltBusinesses, b.ID, b.ID, b, true)
+}
+
+func (s boltStore) GetBusinessByID(id string) (EncryptedBusiness, error) {
+	var b EncryptedBusiness
+	return b, s.get(boltBusinesses, id, &b)
+}
+
+func (s boltStore) GetBusinessByNameHash(nameHash string) (EncryptedBusiness, error) {
+	all, err := s.GetAllBusinesses()
+	for _, entity := range all {
+		if entity.NameHash == nameHash {
+			return entity, nil
+		}
+	}
+	return EncryptedBusiness{}, err
+}
+
+func (s boltStore) GetAllBusinesses() ([]EncryptedBusiness, error) {
+	entities := make([]EncryptedBusiness, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return each(tx, boltBusinesses, func(value []byte) error {
+			var entity EncryptedBusiness
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) UpdateBusinessByID(b EncryptedBusiness, id string) (string, error) {
+	return b.ID, s.put(boltBusinesses, id, id, b, false)
+}
+
+func (s boltStore) CreateCheck(c Check) (string, error) {
+	return c.ID, s.put(boltChecks, c.ID, c.BusinessID, c, true)
+}
+
+func (s boltStore) GetCheckByID(id string) (Check, error) {
+	var c Check
+	return c, s.get(boltChecks, id, &c)
+}
+
+func (s boltStore) GetCheckByName(name string) (Check, error) {
+	all, err := s.GetAllChecks()
+	for _, entity := range all {
+		if entity.Name == name {
+			return entity, nil
+		}
+	}
+	return Check{}, err
+}
+
+func (s boltStore) GetChecks(filter BusinessesRequest) ([]Check, error) {
+	entities := make([]Check, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachOnPage(tx, boltChecks, filter, func(value []byte) error {
+			var entity Check
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) GetAllChecks() ([]Check, error) {
+	entities := make([]Check, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return each(tx, boltChecks, func(value []byte) error {
+			var entity Check
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) UpdateCheckByID(c Check, id string) (string, error) {
+	return c.ID, s.put(boltChecks, id, c.BusinessID, c, false)
+}
+
+func (s boltStore) DeleteCheckByID(id string) error {
+	return s.remove(boltChecks, id)
+}
+
+func (s boltStore) CreateEmployee(e Employee) (string, error) {
+	return e.ID, s.put(boltEmployees, e.ID, e.BusinessID, e, true)
+}
+
+func (s boltStore) GetEmployeeByID(employeeID string) (Employee, error) {
+	var e Employee
+	return e, s.get(boltEmployees, employeeID, &e)
+}
+
+func (s boltStore) GetEmployeeByName(name string) (Employee, error) {
+	all, err := s.GetAllEmployees()
+	for _, entity := range all {
+		if entity.FirstName+entity.LastName == name {
+			return entity, nil
+		}
+	}
+	return Employee{}, err
+}
+
+func (s boltStore) GetEmployees(filter BusinessesRequest) ([]Employee, error) {
+	entities := make([]Employee, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachOnPage(tx, boltEmployees, filter, func(value []byte) error {
+			var entity Employee
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) GetAllEmployees() ([]Employee, error) {
+	entities := make([]Employee, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return each(tx, boltEmployees, func(value []byte) error {
+			var entity Employee
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) UpdateEmployeeByID(e Employee, id string) (string, error) {
+	return e.ID, s.put(boltEmployees, id, e.BusinessID, e, false)
+}
+
+func (s boltStore) DeleteEmployeeByID(id string) error {
+	return s.remove(boltEmployees, id)
+}
+
+func (s boltStore) CreateLaborEntry(l LaborEntry) (string, error) {
+	return l.ID, s.put(boltLaborEntries, l.ID, l.BusinessID, l, true)
+}
+
+func (s boltStore) GetLaborEntries(filter BusinessesRequest) ([]LaborEntry, error) {
+	entities := make([]LaborEntry, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachOnPage(tx, boltLaborEntries, filter, func(value []byte) error {
+			var entity LaborEntry
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) GetAllLaborEntries() ([]LaborEntry, error) {
+	entities := make([]LaborEntry, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return each(tx, boltLaborEntries, func(value []byte) error {
+			var entity LaborEntry
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) CreateMenuItem(m MenuItem) (string, error) {
+	return m.ID, s.put(boltMenuItems, m.ID, m.BusinessID, m, true)
+}
+
+func (s boltStore) GetMenuItemByID(id string) (MenuItem, error) {
+	var m MenuItem
+	return m, s.get(boltMenuItems, id, &m)
+}
+
+func (s boltStore) GetMenuItemByName(name string) (MenuItem, error) {
+	all, err := s.GetAllMenuItems()
+	for _, entity := range all {
+		if entity.Name == name {
+			return entity, nil
+		}
+	}
+	return MenuItem{}, err
+}
+
+func (s boltStore) GetMenuItems(filter BusinessesRequest) ([]MenuItem, error) {
+	entities := make([]MenuItem, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachOnPage(tx, boltMenuItems, filter, func(value []byte) error {
+			var entity MenuItem
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) GetAllMenuItems() ([]MenuItem, error) {
+	entities := make([]MenuItem, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return each(tx, boltMenuItems, func(value []byte) error {
+			var entity MenuItem
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) UpdateMenuItemByID(m MenuItem, id string) (string, error) {
+	return m.ID, s.put(boltMenuItems, id, m.BusinessID, m, false)
+}
+
+func (s boltStore) DeleteMenuItemByID(id string) error {
+	return s.remove(boltMenuItems, id)
+}
+
+func (s boltStore) CreateOrderedItem(o OrderedItem) (string, error) {
+	return o.ID, s.put(boltOrderedItems, o.ID, o.BusinessID, o, true)
+}
+
+func (s boltStore) GetOrderedItems(filter BusinessesRequest) ([]OrderedItem, error) {
+	entities := make([]OrderedItem, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachOnPage(tx, boltOrderedItems, filter, func(value []byte) error {
+			var entity OrderedItem
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (s boltStore) GetAllOrderedItems() ([]OrderedItem, error) {
+	entities := make([]OrderedItem, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return each(tx, boltOrderedItems, func(value []byte) error {
+			var entity OrderedItem
+			if err := json.Unmarshal(value, &entity); err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	return entities, err
+}
+
+// Snapshot reads every collection in one read transaction.
+func (s boltStore) Snapshot() (Snapshot, error) {
+	var snapshot Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		targets := []struct {
+			c    boltCollection
+			into interface{}
+		}{
+			{boltBusinesses, &snapshot.Businesses},
+			{boltChecks, &snapshot.Checks},
+			{boltEmployees, &snapshot.Employees},
+			{boltLaborEntries, &snapshot.LaborEntries},
+			{boltMenuItems, &snapshot.MenuItems},
+			{boltOrderedItems, &snapshot.OrderedItems},
+		}
+		for _, t := range targets {
+			// gather the collection as a JSON array, decoded into its slice
+			values := [][]byte{}
+			if err := each(tx, t.c, func(value []byte) error {
+				values = append(values, value)
+				return nil
+			}); err != nil {
+				return err
+			}
+			array := append(append([]byte("["), bytes.Join(values, []byte(","))...), ']')
+			if err := json.Unmarshal(array, t.into); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return snapshot, err
+}
+
+// Restore replaces the records with those of snapshot in one write
+// transaction, so a failed restore leaves them as they were.
+func (s boltStore) Restore(snapshot Snapshot) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, c := range boltCollections {
+			if err := tx.DeleteBucket(c.records); err != nil {
+				return err
+			}
+			if c.byBusiness != nil {
+				if err := tx.DeleteBucket(c.byBusiness); err != nil {
+					return err
+				}
+			}
+		}
+		if err := createBuckets(tx); err != nil {
+			return err
+		}
+		for _, b := range snapshot.Businesses {
+			if err := put(tx, boltBusinesses, b.ID, b.ID, b, true); err != nil {
+				return err
+			}
+		}
+		for _, c := range snapshot.Checks {
+			if err := put(tx, boltChecks, c.ID, c.BusinessID, c, true); err != nil {
+				return err
+			}
+		}
+		for _, e := range snapshot.Employees {
+			if err := put(tx, boltEmployees, e.ID, e.BusinessID, e, true); err != nil {
+				return err
+			}
+		}
+		for _, l := range snapshot.LaborEntries {
+			if err := put(tx, boltLaborEntries, l.ID, l.BusinessID, l, true); err != nil {
+				return err
+			}
+		}
+		for _, m := range snapshot.MenuItems {
+			if err := put(tx, boltMenuItems, m.ID, m.BusinessID, m, true); err != nil {
+				return err
+			}
+		}
+		for _, o := range snapshot.OrderedItems {
+			if err := put(tx, boltOrderedItems, o.ID, o.BusinessID, o, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Business names are stored envelope encrypted: each business gets its own
+// AES-256 data key, which encrypts the name with AES-GCM and is itself
+// encrypted ("wrapped") with AES-GCM under a master key of the keyring shared
+// with the reporting service. Only the wrapped data key is stored.
+
+// dataKeySize is the size of master and data keys, selecting AES-256.
+const dataKeySize = 32
+
+var errBadKeyFile = errors.New("keyring lines must be key ID:base64 32 byte key")
+
+// keyring holds the master keys data keys are wrapped with, read from a file
+// of "key ID:base64 key" lines, oldest first. New data keys are wrapped with
+// the last, current key; the older ones stay in use for the records still
+// wrapped with them until they are re-wrapped. The file is read again
+// whenever it changes, so a key is rotated in without a restart.
+type keyring struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	keys    map[string][]byte
+	current string
+}
+
+func loadKeyring(path string) (*keyring, error) {
+	k := &keyring{path: path}
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// currentKey returns the key new data keys are wrapped with.
+func (k *keyring) currentKey() (id string, key []byte) {
+	k.load()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.current, k.keys[k.current]
+}
+
+// key returns the key of id, if it is still in the keyring.
+func (k *keyring) key(id string) ([]byte, bool) {
+	k.load()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// load reads the file again if it changed. If it cannot be read, the keys
+// last read stay in use.
+func (k *keyring) load() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if info.ModTime().Equal(k.modTime) && k.keys != nil {
+		return nil
+	}
+
+	file, err := os.Open(k.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	keys, current := make(map[string][]byte), ""
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("%s:%d: %v", k.path, n, errBadKeyFile)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil || len(key) != dataKeySize {
+			return fmt.Errorf("%s:%d: %v", k.path, n, errBadKeyFile)
+		}
+		keys[parts[0]], current = key, parts[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if current == "" {
+		return fmt.Errorf("%s: no key", k.path)
+	}
+	k.keys, k.current, k.modTime = keys, current, info.ModTime()
+	return nil
+}
+
+// loadNameHashKey reads the HMAC key of NameHash from a file of one
+// base64 key line.
+func loadNameHashKey(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(key) < dataKeySize {
+			break
+		}
+		return key, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%s: name hash key must be 32 or more base64 bytes", path)
+}
+
+// envelope encrypts business names and hashes them for lookups.
+type envelope struct {
+	keys    *keyring
+	hashKey []byte
+}
+
+// encryptBusiness returns b with its name encrypted under a new data key. The
+// ciphertext is bound to the business ID, the wrapped data key to its key ID,
+// so neither can be moved onto another record.
+func (e envelope) encryptBusiness(b Business) (EncryptedBusiness, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return EncryptedBusiness{}, err
+	}
+	nonce, name, err := seal(dataKey, []byte(b.Name), []byte(b.ID))
+	if err != nil {
+		return EncryptedBusiness{}, err
+	}
+	envelopeKeyID := newID("")
+	serviceKeyID, serviceKey := e.keys.currentKey()
+	wrapped, err := wrap(serviceKey, dataKey, envelopeKeyID)
+	if err != nil {
+		return EncryptedBusiness{}, err
+	}
+
+	encrypted := EncryptedBusiness{
+		Business:             b,
+		NameHash:             e.nameHash(b.Name),
+		EncryptedEnvelopeKey: wrapped,
+		EnvelopeKeyID:        envelopeKeyID,
+		ServiceKeyID:         serviceKeyID,
+		InitializationVector: base64.StdEncoding.EncodeToString(nonce),
+	}
+	encrypted.Name = base64.StdEncoding.EncodeToString(name)
+	return encrypted, nil
+}
+
+// rewrapBusiness wraps the data key of b with the current master key, if it
+// is wrapped with an older one. The data key, and so the encrypted name, stay
+// as they are.
+func (e envelope) rewrapBusiness(b EncryptedBusiness) (EncryptedBusiness, bool, error) {
+	serviceKeyID, serviceKey := e.keys.currentKey()
+	if b.ServiceKeyID == serviceKeyID {
+		return b, false, nil
+	}
+	oldKey, ok := e.keys.key(b.ServiceKeyID)
+	if !ok {
+		return b, false, fmt.Errorf("business %s: master key %q is not in the keyring", b.ID, b.ServiceKeyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(b.EncryptedEnvelopeKey)
+	if err != nil {
+		return b, false, err
+	}
+	dataKey, err := open(oldKey, sealed, []byte(b.EnvelopeKeyID))
+	if err != nil {
+		return b, false, fmt.Errorf("business %s: %v", b.ID, err)
+	}
+	wrapped, err := wrap(serviceKey, dataKey, b.EnvelopeKeyID)
+	if err != nil {
+		return b, false, err
+	}
+	b.EncryptedEnvelopeKey, b.ServiceKeyID = wrapped, serviceKeyID
+	return b, true, nil
+}
+
+// nameHash is the hex HMAC-SHA256 of a business name, trimmed and lower
+// cased, so businesses can be looked up by name without storing it.
+func (e envelope) nameHash(name string) string {
+	mac := hmac.New(sha256.New, e.hashKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(name))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// wrap encrypts dataKey under serviceKey, bound to envelopeKeyID, and returns
+// the base64 nonce and ciphertext.
+func wrap(serviceKey, dataKey []byte, envelopeKeyID string) (string, error) {
+	nonce, wrapped, err := seal(serviceKey, dataKey, []byte(envelopeKeyID))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(append(nonce, wrapped...)), nil
+}
+
+// seal encrypts plaintext with AES-GCM under key and a new random nonce.
+func seal(key, plaintext, additionalData []byte) (nonce, ciphertext []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts sealed, an AES-GCM nonce followed by the ciphertext, under
+// key.
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+}
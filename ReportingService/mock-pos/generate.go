@@ -0,0 +1,531 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/satori/go.uuid"
+	"gopkg.in/inf.v0"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+// generatorConfig shapes the synthetic dataset an empty store is seeded with
+// by -generate. Its JSON file overrides the defaults, e.g.:
+//
+//	{"seed": 7, "businesses": 3, "start": "2018-01-01", "end": "2018-12-31",
+//	 "checks_per_day": 200, "void_rate": 0.05}
+type generatorConfig struct {
+	Seed       int64    `json:"seed"`       // the same seed and config generate the same records and IDs
+	Businesses int      `json:"businesses"` // with IDs businessID1, businessID2, ...
+	Start      date     `json:"start"`      // first day of trading, 2006-01-02
+	End        date     `json:"end"`        // last day of trading
+	TimeZones  []string `json:"time_zones"` // of the businesses, in turn
+	Hours      [][]int  `json:"hours"`      // opening hours of the businesses, in turn, as in Business.Hours
+
+	Menu               []menuItemConfig `json:"menu"`                 // the items menus are picked from
+	MenuSize           int              `json:"menu_size"`            // items per business
+	PriceChangeDays    int              `json:"price_change_days"`    // between price reviews, 0 for fixed prices
+	PriceChangePercent float64          `json:"price_change_percent"` // largest rise at a review; prices fall by up to half of it
+
+	Staff         int       `json:"staff"`           // employees per business
+	PayRates      [2]string `json:"pay_rates"`       // lowest and highest hourly pay rate
+	ShiftHours    int       `json:"shift_hours"`     // longest shift opening hours are split into
+	StaffPerShift int       `json:"staff_per_shift"` // employees clocked in per shift
+	DaysOff       int       `json:"days_off"`        // per employee per week
+
+	ChecksPerDay  float64    `json:"checks_per_day"`  // on average, before the weekday factor
+	Weekdays      [7]float64 `json:"weekdays"`        // check volume factor, Sunday first
+	Peaks         []peak     `json:"peaks"`           // busy times of day, e.g. lunch and dinner
+	ItemsPerCheck float64    `json:"items_per_check"` // on average
+	VoidRate      float64    `json:"void_rate"`       // share of ordered items voided
+}
+
+type menuItemConfig struct {
+	Name  string `json:"name"`
+	Cost  string `json:"cost"`
+	Price string `json:"price"` // at the start, before price reviews
+}
+
+// peak adds Weight times the checks of a quiet hour around Hour, local time
+// (e.g. 12.5 for half past noon), tailing off over Width hours.
+type peak struct {
+	Hour   float64 `json:"hour"`
+	Width  float64 `json:"width"`
+	Weight float64 `json:"weight"`
+}
+
+// date is a day, 2006-01-02 in JSON.
+type date struct {
+	time.Time
+}
+
+func (d *date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+func defaultGeneratorConfig() generatorConfig {
+	return generatorConfig{
+		Seed:       1,
+		Businesses: 1,
+		Start:      date{time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		End:        date{time.Date(2018, 3, 31, 0, 0, 0, 0, time.UTC)},
+		TimeZones:  []string{"America/Los_Angeles", "America/New_York", "America/Chicago"},
+		Hours: [][]int{
+			{11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22},
+			{7, 8, 9, 10, 11, 12, 13, 14, 15},
+			{11, 12, 13, 14, 17, 18, 19, 20, 21},
+		},
+
+		Menu: []menuItemConfig{
+			{"Buffalo Wing", "10.00", "15.00"},
+			{"Original Recipe", "10.00", "15.00"},
+			{"Fried Chicken Sandwich", "3.20", "9.50"},
+			{"Chicken Tenders", "2.80", "8.00"},
+			{"Mac and Cheese", "0.90", "4.50"},
+			{"Mashed Potatoes", "0.60", "3.50"},
+			{"Coleslaw", "0.40", "3.00"},
+			{"Biscuit", "0.25", "2.00"},
+			{"Cornbread", "0.30", "2.50"},
+			{"Sweet Tea", "0.20", "2.50"},
+			{"Lemonade", "0.35", "3.00"},
+			{"Peach Cobbler", "1.10", "5.50"},
+		},
+		MenuSize:           8,
+		PriceChangeDays:    30,
+		PriceChangePercent: 8,
+
+		Staff:         10,
+		PayRates:      [2]string{"15.00", "25.00"},
+		ShiftHours:    6,
+		StaffPerShift: 3,
+		DaysOff:       2,
+
+		ChecksPerDay:  120,
+		Weekdays:      [7]float64{1.2, 0.8, 0.8, 0.9, 1.0, 1.3, 1.4},
+		Peaks:         []peak{{12.5, 1, 3}, {19, 1.5, 4}},
+		ItemsPerCheck: 3,
+		VoidRate:      0.03,
+	}
+}
+
+// loadGeneratorConfig overrides the default config with the file at path.
+func loadGeneratorConfig(path string) (generatorConfig, error) {
+	config := defaultGeneratorConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+func (c generatorConfig) validate() error {
+	switch {
+	case c.Businesses < 1:
+		return fmt.Errorf("businesses must be at least 1")
+	case c.End.Before(c.Start.Time):
+		return fmt.Errorf("end is before start")
+	case len(c.TimeZones) == 0:
+		return fmt.Errorf("no time_zones")
+	case len(c.Hours) == 0:
+		return fmt.Errorf("no hours")
+	case c.MenuSize < 1 || c.MenuSize > len(c.Menu):
+		return fmt.Errorf("menu_size must be between 1 and the %d items of the menu", len(c.Menu))
+	case c.PriceChangeDays < 0 || c.PriceChangePercent < 0:
+		return fmt.Errorf("price_change_days and price_change_percent must not be negative")
+	case c.Staff < 1 || c.ShiftHours < 1 || c.StaffPerShift < 1:
+		return fmt.Errorf("staff, shift_hours and staff_per_shift must be at least 1")
+	case c.DaysOff < 0 || c.DaysOff > 6:
+		return fmt.Errorf("days_off must be between 0 and 6")
+	case c.ChecksPerDay < 0 || c.ItemsPerCheck < 1:
+		return fmt.Errorf("checks_per_day must not be negative, items_per_check must be at least 1")
+	case c.VoidRate < 0 || c.VoidRate > 1:
+		return fmt.Errorf("void_rate must be between 0 and 1")
+	}
+	for _, zone := range c.TimeZones {
+		if _, err := time.LoadLocation(zone); err != nil {
+			return err
+		}
+	}
+	for _, hours := range c.Hours {
+		if len(hours) == 0 {
+			return fmt.Errorf("empty opening hours")
+		}
+		for _, h := range hours {
+			if h < 0 || h > 23 {
+				return fmt.Errorf("opening hour %d is not between 0 and 23", h)
+			}
+		}
+	}
+	for _, m := range c.Menu {
+		if _, err := cents(m.Cost); err != nil {
+			return fmt.Errorf("menu item %s: %v", m.Name, err)
+		}
+		if _, err := cents(m.Price); err != nil {
+			return fmt.Errorf("menu item %s: %v", m.Name, err)
+		}
+	}
+	for _, rate := range c.PayRates {
+		if _, err := cents(rate); err != nil {
+			return fmt.Errorf("pay_rates: %v", err)
+		}
+	}
+	return nil
+}
+
+// cents parses an amount of money, e.g. "12.50", rounded to the cent.
+func cents(amount string) (int64, error) {
+	d, ok := new(inf.Dec).SetString(amount)
+	if !ok {
+		return 0, fmt.Errorf("bad amount %q", amount)
+	}
+	return d.Round(d, 2, inf.RoundHalfUp).UnscaledBig().Int64(), nil
+}
+
+var (
+	businessAdjectives = []string{"Golden", "Rusty", "Happy", "Blue", "Little", "Lucky", "Smoky", "Crispy"}
+	businessNouns      = []string{"Spoon", "Skillet", "Rooster", "Anchor", "Lantern", "Oven", "Fork", "Barrel"}
+	firstNames         = []string{"John", "Mary", "Ana", "Luis", "Grace", "Omar", "Mei", "Tom", "Priya", "Sam", "Nina", "Kofi", "Eva", "Raj", "Lena", "Hugo"}
+	lastNames          = []string{"Wayne", "Poppins", "Garcia", "Smith", "Nguyen", "Okafor", "Kim", "Rossi", "Patel", "Meyer", "Silva", "Cohen", "Haddad", "Berg", "Tanaka", "Lopez"}
+	roles              = []string{"Server", "Cook", "Server", "Host", "Bartender", "Cook", "Dishwasher"}
+)
+
+// generator builds a dataset from its config, drawing every random choice and
+// ID from one source seeded with config.Seed.
+type generator struct {
+	config   generatorConfig
+	rng      *rand.Rand
+	snapshot Snapshot
+}
+
+// generate returns the dataset of config, with the business names encrypted
+// by env. Only their encryption differs between runs.
+func generate(config generatorConfig, env envelope) (Snapshot, error) {
+	g := generator{config: config, rng: rand.New(rand.NewSource(config.Seed))}
+	g.snapshot = Snapshot{
+		Businesses:   make([]EncryptedBusiness, 0),
+		Checks:       make([]Check, 0),
+		Employees:    make([]Employee, 0),
+		LaborEntries: make([]LaborEntry, 0),
+		MenuItems:    make([]MenuItem, 0),
+		OrderedItems: make([]OrderedItem, 0),
+	}
+	for i := 0; i < config.Businesses; i++ {
+		if err := g.business(i, env); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	return g.snapshot, nil
+}
+
+func (g *generator) id() string {
+	var u uuid.UUID
+	g.rng.Read(u[:])
+	u.SetVersion(uuid.V4)
+	u.SetVariant(uuid.VariantRFC4122)
+	return u.String()
+}
+
+// business generates the i-th business and its records over the days of
+// trading, which are counted in its own time zone.
+func (g *generator) business(i int, env envelope) error {
+	c := g.config
+	loc, err := time.LoadLocation(c.TimeZones[i%len(c.TimeZones)])
+	if err != nil {
+		return err
+	}
+	start := time.Date(c.Start.Year(), c.Start.Month(), c.Start.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(c.End.Year(), c.End.Month(), c.End.Day(), 0, 0, 0, 0, loc)
+	opened := start.AddDate(0, 0, -30-g.rng.Intn(365))
+
+	name := fmt.Sprintf("The %s %s", businessAdjectives[i%len(businessAdjectives)], businessNouns[i/len(businessAdjectives)%len(businessNouns)])
+	if i >= len(businessAdjectives)*len(businessNouns) {
+		name = fmt.Sprintf("%s %d", name, i/(len(businessAdjectives)*len(businessNouns))+1)
+	}
+	business := Business{
+		ID:        fmt.Sprintf("businessID%d", i+1),
+		Name:      name,
+		Hours:     c.Hours[i%len(c.Hours)],
+		TimeZone:  loc.String(),
+		UpdatedAt: opened.UTC(),
+		CreatedAt: opened.UTC(),
+	}
+	encrypted, err := env.encryptBusiness(business)
+	if err != nil {
+		return err
+	}
+	g.snapshot.Businesses = append(g.snapshot.Businesses, encrypted)
+
+	menu := g.menu(business.ID, opened)
+	staff := g.staff(business.ID, opened, start)
+	checks := 0
+	for day, n := start, 0; !day.After(end); n++ {
+		if c.PriceChangeDays > 0 && n > 0 && n%c.PriceChangeDays == 0 {
+			g.reviewPrices(menu, day)
+		}
+		shifts := g.shifts(business, staff, day)
+		checks = g.checks(business, menu, shifts, day, checks)
+		day = start.AddDate(0, 0, n+1)
+	}
+	g.snapshot.MenuItems = append(g.snapshot.MenuItems, menu...)
+	return nil
+}
+
+// menu picks the items of a business. Items first on it sell best.
+func (g *generator) menu(businessID string, opened time.Time) []MenuItem {
+	picks := g.rng.Perm(len(g.config.Menu))[:g.config.MenuSize]
+	menu := make([]MenuItem, 0, len(picks))
+	for _, p := range picks {
+		item := g.config.Menu[p]
+		cost, _ := cents(item.Cost)
+		price, _ := cents(item.Price)
+		menu = append(menu, MenuItem{
+			ID:         g.id(),
+			BusinessID: businessID,
+			Name:       item.Name,
+			Cost:       inf.NewDec(cost, 2),
+			Price:      inf.NewDec(price, 2),
+			UpdatedAt:  opened.UTC(),
+			CreatedAt:  opened.UTC(),
+		})
+	}
+	return menu
+}
+
+// reviewPrices changes the price of about half the items, up by at most
+// PriceChangePercent or down by at most half of it. Items sold before keep
+// the price they were sold at.
+func (g *generator) reviewPrices(menu []MenuItem, day time.Time) {
+	for i := range menu {
+		if g.rng.Intn(2) == 0 {
+			continue
+		}
+		change := (g.rng.Float64()*1.5 - 0.5) * g.config.PriceChangePercent / 100
+		price := int64(math.Round(float64(menu[i].Price.UnscaledBig().Int64()) * (1 + change)))
+		if price < 1 {
+			price = 1
+		}
+		menu[i].Price = inf.NewDec(price, 2)
+		menu[i].UpdatedAt = day.UTC()
+	}
+}
+
+// staff hires the employees of a business between its opening and the first
+// day of trading.
+func (g *generator) staff(businessID string, opened, start time.Time) []Employee {
+	c := g.config
+	low, _ := cents(c.PayRates[0])
+	high, _ := cents(c.PayRates[1])
+	if high < low {
+		low, high = high, low
+	}
+	staff := make([]Employee, 0, c.Staff)
+	for k := 0; k < c.Staff; k++ {
+		hired := opened.Add(time.Duration(g.rng.Int63n(int64(start.Sub(opened))))).Truncate(time.Second)
+		// pay rates in steps of 25 cents
+		rate := low + g.rng.Int63n((high-low)/25+1)*25
+		e := Employee{
+			ID:         g.id(),
+			BusinessID: businessID,
+			FirstName:  firstNames[g.rng.Intn(len(firstNames))],
+			LastName:   lastNames[g.rng.Intn(len(lastNames))],
+			PayRate:    inf.NewDec(rate, 2),
+			UpdatedAt:  hired.UTC(),
+			CreatedAt:  hired.UTC(),
+		}
+		staff = append(staff, e)
+		g.snapshot.Employees = append(g.snapshot.Employees, e)
+	}
+	return staff
+}
+
+// shifts clocks employees in and out over the opening hours of day, each
+// stretch of consecutive hours split into shifts of at most ShiftHours. An
+// employee has DaysOff days off a week, on the same weekdays every week.
+// Shifts take the employees on duty in turn, from a random one, skipping
+// those still clocked in from the shift before, so a shift is short-staffed
+// rather than have anyone work two at once.
+func (g *generator) shifts(b Business, staff []Employee, day time.Time) []LaborEntry {
+	c := g.config
+	onDuty := make([]int, 0, len(staff))
+	for k := range staff {
+		if (int(day.Weekday())-k%7+7)%7 >= c.DaysOff {
+			onDuty = append(onDuty, k)
+		}
+	}
+	perShift := c.StaffPerShift
+	if perShift > len(onDuty) {
+		perShift = len(onDuty)
+	}
+	next := 0
+	if len(onDuty) > 0 {
+		next = g.rng.Intn(len(onDuty))
+	}
+	clockedOut := make(map[int]time.Time) // end of the last shift of each employee
+
+	entries := make([]LaborEntry, 0)
+	for _, stretch := range openStretches(b.Hours) {
+		hours := stretch[1] - stretch[0]
+		n := (hours + c.ShiftHours - 1) / c.ShiftHours
+		for s := 0; s < n; s++ {
+			from := time.Date(day.Year(), day.Month(), day.Day(), stretch[0]+s*hours/n, 0, 0, 0, day.Location())
+			to := time.Date(day.Year(), day.Month(), day.Day(), stretch[0]+(s+1)*hours/n, 0, 0, 0, day.Location())
+			for j := 0; j < perShift; j++ {
+				clockIn := from.Add(-time.Duration(g.rng.Intn(15*60)) * time.Second)
+				clockOut := to.Add(time.Duration(g.rng.Intn(20*60)) * time.Second)
+				k := -1
+				for tries := 0; tries < len(onDuty) && k < 0; tries++ {
+					if candidate := onDuty[next%len(onDuty)]; !clockedOut[candidate].After(clockIn) {
+						k = candidate
+					}
+					next++
+				}
+				if k < 0 {
+					break
+				}
+				clockedOut[k] = clockOut
+				entries = append(entries, LaborEntry{
+					ID:         g.id(),
+					BusinessID: b.ID,
+					EmployeeID: staff[k].ID,
+					Name:       roles[k%len(roles)],
+					ClockIn:    clockIn.UTC(),
+					ClockOut:   clockOut.UTC(),
+					PayRate:    staff[k].PayRate,
+					UpdatedAt:  clockOut.UTC(),
+					CreatedAt:  clockIn.UTC(),
+				})
+			}
+		}
+	}
+	g.snapshot.LaborEntries = append(g.snapshot.LaborEntries, entries...)
+	return entries
+}
+
+// openStretches returns the stretches of consecutive opening hours, as
+// [first hour, hour after the last) pairs.
+func openStretches(hours []int) [][2]int {
+	sorted := append([]int(nil), hours...)
+	sort.Ints(sorted)
+	stretches := make([][2]int, 0)
+	for _, h := range sorted {
+		if n := len(stretches); n > 0 && stretches[n-1][1] >= h {
+			if stretches[n-1][1] == h {
+				stretches[n-1][1] = h + 1
+			}
+			continue
+		}
+		stretches = append(stretches, [2]int{h, h + 1})
+	}
+	return stretches
+}
+
+// checks opens the checks of day, around ChecksPerDay times the weekday
+// factor, in the opening hours with more at the peaks, each served by an
+// employee clocked in at the time. number is the count of checks of the
+// business so far, which it returns updated.
+func (g *generator) checks(b Business, menu []MenuItem, shifts []LaborEntry, day time.Time, number int) int {
+	c := g.config
+	weights := make([]float64, 0, len(b.Hours))
+	total := 0.0
+	for _, h := range b.Hours {
+		w := 1.0
+		for _, p := range c.Peaks {
+			if p.Width > 0 {
+				d := (float64(h) + 0.5 - p.Hour) / p.Width
+				w += p.Weight * math.Exp(-d*d/2)
+			}
+		}
+		weights = append(weights, w)
+		total += w
+	}
+
+	expected := c.ChecksPerDay * c.Weekdays[day.Weekday()]
+	count := int(math.Round(expected + math.Sqrt(expected)*g.rng.NormFloat64()))
+	if count < 0 {
+		count = 0
+	}
+	opens := make([]time.Time, 0, count)
+	for i := 0; i < count; i++ {
+		pick := g.rng.Float64() * total
+		h := 0
+		for h < len(weights)-1 && pick >= weights[h] {
+			pick -= weights[h]
+			h++
+		}
+		opens = append(opens, time.Date(day.Year(), day.Month(), day.Day(), b.Hours[h], 0, g.rng.Intn(3600), 0, day.Location()))
+	}
+	sort.Slice(opens, func(i, j int) bool { return opens[i].Before(opens[j]) })
+
+	for _, opened := range opens {
+		number++
+		closed := opened.Add(time.Duration(20+g.rng.Intn(70)) * time.Minute)
+		check := Check{
+			ID:         g.id(),
+			BusinessID: b.ID,
+			EmployeeID: g.server(shifts, opened),
+			Name:       fmt.Sprintf("check%d", number),
+			Closed:     true,
+			ClosedAt:   closed.UTC(),
+			UpdatedAt:  closed.UTC(),
+			CreatedAt:  opened.UTC(),
+		}
+		g.snapshot.Checks = append(g.snapshot.Checks, check)
+
+		items := 1 + int(math.Round(g.rng.ExpFloat64()*(c.ItemsPerCheck-1)))
+		for i := 0; i < items; i++ {
+			// items first on the menu are ordered most
+			item := menu[int(float64(len(menu))*math.Pow(g.rng.Float64(), 2))]
+			ordered := opened.Add(time.Duration(g.rng.Int63n(int64(closed.Sub(opened) / 2)))).Truncate(time.Second)
+			o := OrderedItem{
+				ID:         g.id(),
+				BusinessID: b.ID,
+				EmployeeID: check.EmployeeID,
+				CheckID:    check.ID,
+				ItemID:     item.ID,
+				Name:       item.Name,
+				Cost:       item.Cost,
+				Price:      item.Price,
+				Voided:     g.rng.Float64() < c.VoidRate,
+				UpdatedAt:  ordered.UTC(),
+				CreatedAt:  ordered.UTC(),
+			}
+			if o.Voided {
+				o.UpdatedAt = closed.UTC()
+			}
+			g.snapshot.OrderedItems = append(g.snapshot.OrderedItems, o)
+		}
+	}
+	return number
+}
+
+// server picks an employee clocked in at t, or none if nobody is.
+func (g *generator) server(shifts []LaborEntry, t time.Time) string {
+	working := make([]string, 0, len(shifts))
+	for _, s := range shifts {
+		if !t.Before(s.ClockIn) && t.Before(s.ClockOut) {
+			working = append(working, s.EmployeeID)
+		}
+	}
+	if len(working) == 0 {
+		return ""
+	}
+	return working[g.rng.Intn(len(working))]
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testEnvelope returns an envelope with a one key keyring written to a
+// temporary directory.
+func testEnvelope(t *testing.T) envelope {
+	path := filepath.Join(t.TempDir(), "master.key")
+	key := base64.StdEncoding.EncodeToString(make([]byte, dataKeySize))
+	if err := os.WriteFile(path, []byte("test:"+key+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return envelope{keys: keys, hashKey: []byte("test hash key")}
+}
+
+func testGeneratorConfig() generatorConfig {
+	c := defaultGeneratorConfig()
+	c.Businesses = 2
+	c.End = date{c.Start.AddDate(0, 0, 13)}
+	c.ChecksPerDay = 20
+	return c
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	env := testEnvelope(t)
+	first, err := generate(testGeneratorConfig(), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := generate(testGeneratorConfig(), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Checks) == 0 || len(first.LaborEntries) == 0 || len(first.OrderedItems) == 0 {
+		t.Fatalf("generated %d checks, %d labor entries, %d ordered items", len(first.Checks), len(first.LaborEntries), len(first.OrderedItems))
+	}
+
+	// only the encryption of the business names differs
+	if len(first.Businesses) != len(second.Businesses) {
+		t.Fatalf("%d then %d businesses", len(first.Businesses), len(second.Businesses))
+	}
+	for i := range first.Businesses {
+		a, b := first.Businesses[i], second.Businesses[i]
+		a.Name, b.Name = "", "" // encrypted
+		if !reflect.DeepEqual(a.Business, b.Business) || a.NameHash != b.NameHash {
+			t.Errorf("business %d: %+v then %+v", i, a, b)
+		}
+	}
+	first.Businesses, second.Businesses = nil, nil
+	if !reflect.DeepEqual(first, second) {
+		t.Error("the same config generated different records")
+	}
+
+	other := testGeneratorConfig()
+	other.Seed = 2
+	third, err := generate(other, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Checks[0].ID == first.Checks[0].ID {
+		t.Errorf("seeds 1 and 2 generated check %s", first.Checks[0].ID)
+	}
+}
+
+func TestShiftsDoNotOverlap(t *testing.T) {
+	short := testGeneratorConfig()
+	// 13 hours in five shifts, of more staff than is on duty
+	short.Hours = [][]int{{8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}}
+	short.ShiftHours = 3
+	short.Staff = 4
+	short.StaffPerShift = 3
+	short.DaysOff = 1
+
+	for name, config := range map[string]generatorConfig{"default": testGeneratorConfig(), "short-staffed": short} {
+		snapshot, err := generate(config, testEnvelope(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		shifts := make(map[string][]LaborEntry)
+		for _, l := range snapshot.LaborEntries {
+			shifts[l.EmployeeID] = append(shifts[l.EmployeeID], l)
+		}
+		if len(shifts) < 2 {
+			t.Errorf("%s: %d employees worked", name, len(shifts))
+		}
+		for employee, entries := range shifts {
+			sort.Slice(entries, func(i, j int) bool { return entries[i].ClockIn.Before(entries[j].ClockIn) })
+			for i := 1; i < len(entries); i++ {
+				if prev := entries[i-1]; entries[i].ClockIn.Before(prev.ClockOut) {
+					t.Errorf("%s: employee %s clocked in at %s, still on the shift of %s to %s", name, employee,
+						entries[i].ClockIn.Format(time.RFC3339), prev.ClockIn.Format(time.RFC3339), prev.ClockOut.Format(time.RFC3339))
+				}
+			}
+		}
+	}
+}
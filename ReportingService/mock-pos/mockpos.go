@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"github.com/go-kit/kit/log"
-	//"github.com/lib/pq"
-	"github.com/satori/go.uuid"
+	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel"
 	"gopkg.in/inf.v0"
 	"net/http"
 	"os"
+	"reportingservice/internal/tracing"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/kit/endpoint"
@@ -21,6 +24,7 @@ type Business struct {
 	ID string `json:"id"`
 	Name string `json:"name"`
 	Hours []int `json:"hours"`
+	TimeZone string `json:"time_zone"` // IANA Time Zone database name, e.g. America/Los_Angeles
 	UpdatedAt time.Time `json:"updated_at"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -94,19 +98,29 @@ type LaborEntry struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-var (
-	theBusiness         = make([]Business, 0)
-	theCheck            = make([]Check, 0)
-	theOrderedItem      = make([]OrderedItem, 0)
-	theMenuItem         = make([]MenuItem, 0)
-	theEmployee         = make([]Employee, 0)
-	theLaborEntry       = make([]LaborEntry, 0)
-)
-
 // MockPOSService provides mock POS operations.
 type MockPOSService interface {
-	Businesses(BusinessesRequest) (Business, error)
-	MenuItems(string) int
+	Businesses(BusinessesRequest) (EncryptedBusiness, error)
+	Checks(BusinessesRequest) ([]Check, error)
+	Employees(BusinessesRequest) ([]Employee, error)
+	LaborEntries(BusinessesRequest) ([]LaborEntry, error)
+	MenuItems(BusinessesRequest) ([]MenuItem, error)
+	OrderedItems(BusinessesRequest) ([]OrderedItem, error)
+
+	CreateCheck(Check) (Check, error)
+	UpdateCheck(Check) (Check, error)
+	DeleteCheck(string) error
+	CreateEmployee(Employee) (Employee, error)
+	UpdateEmployee(Employee) (Employee, error)
+	DeleteEmployee(string) error
+	CreateMenuItem(MenuItem) (MenuItem, error)
+	UpdateMenuItem(MenuItem) (MenuItem, error)
+	DeleteMenuItem(string) error
+
+	RewrapBusinesses() (RewrapResult, error)
+	Snapshot() (Snapshot, error)
+	Restore(Snapshot) error
+	LoadScenario(scenario) (Snapshot, error)
 	
 	//Ping() (string, error)
 	//CreateDB() ([]interface{}, error)
@@ -129,20 +143,42 @@ type MockPOSService interface {
 }
 
 // mockPOSService is a concrete implementation of MockPOSService
-type mockPOSService struct{}
+type mockPOSService struct {
+	envelope envelope
+	store    Storage
+}
 
-func (s mockPOSService) Businesses(req BusinessesRequest) (Business, error) {
+// Businesses looks the business up by business_id, or else by name through
+// its NameHash.
+func (s mockPOSService) Businesses(req BusinessesRequest) (EncryptedBusiness, error) {
+	if req.BusinessID == "" && req.Name == "" {
+		return EncryptedBusiness{}, ErrEmpty
+	}
 	if req.BusinessID == "" {
-		return Business{}, ErrEmpty
+		return s.store.GetBusinessByNameHash(s.envelope.nameHash(req.Name))
 	}
 
-	bus, _ := GetBusinessByID(req.BusinessID)
+	return s.store.GetBusinessByID(req.BusinessID)
+}
+
+func (s mockPOSService) Checks(req BusinessesRequest) ([]Check, error) {
+	return s.store.GetChecks(req)
+}
+
+func (s mockPOSService) Employees(req BusinessesRequest) ([]Employee, error) {
+	return s.store.GetEmployees(req)
+}
+
+func (s mockPOSService) LaborEntries(req BusinessesRequest) ([]LaborEntry, error) {
+	return s.store.GetLaborEntries(req)
+}
 
-	return bus, nil
+func (s mockPOSService) MenuItems(req BusinessesRequest) ([]MenuItem, error) {
+	return s.store.GetMenuItems(req)
 }
 
-func (mockPOSService) MenuItems(s string) int {
-	return len(s)
+func (s mockPOSService) OrderedItems(req BusinessesRequest) ([]OrderedItem, error) {
+	return s.store.GetOrderedItems(req)
 }
 
 //func (s reportingService) GetMenuItemsByBusinessID(id string) ([]MenuItem, error) {
@@ -157,10 +193,54 @@ func (mockPOSService) MenuItems(s string) int {
 var ErrEmpty = errors.New("empty string")
 
 // For each method, we define request and response structs
+// BusinessesRequest constrains the results of a read. Empty filters match
+// every entity; the date ranges are [start, end). The clocked range keeps the
+// labor entries whose shift overlaps it, and concerns no other records.
 type BusinessesRequest struct {
 	Limit int `json:"limit"`
 	Offset int `json:"offset"`
 	BusinessID string `json:"business_id"`
+	Name string `json:"name"` // looks /businesses up by name
+	UpdatedStart time.Time `json:"updated_start"`
+	UpdatedEnd time.Time `json:"updated_end"`
+	CreatedStart time.Time `json:"created_start"`
+	CreatedEnd time.Time `json:"created_end"`
+	ClockedStart time.Time `json:"clocked_start"`
+	ClockedEnd time.Time `json:"clocked_end"`
+}
+
+// matches reports whether an entity passes the business and date filters.
+func (req BusinessesRequest) matches(businessID string, updatedAt, createdAt time.Time) bool {
+	if req.BusinessID != "" && businessID != req.BusinessID {
+		return false
+	}
+	return inRange(updatedAt, req.UpdatedStart, req.UpdatedEnd) &&
+		inRange(createdAt, req.CreatedStart, req.CreatedEnd)
+}
+
+// onShift reports whether a shift from clockIn to clockOut overlaps the
+// clocked range. A shift still clocked in, with a zero clockOut, overlaps
+// every range ending after its clock in.
+func (req BusinessesRequest) onShift(clockIn, clockOut time.Time) bool {
+	if !req.ClockedEnd.IsZero() && !clockIn.Before(req.ClockedEnd) {
+		return false
+	}
+	if !req.ClockedStart.IsZero() && !clockOut.IsZero() && !clockOut.After(req.ClockedStart) {
+		return false
+	}
+	return true
+}
+
+// inRange reports whether start <= t < end, a zero start or end leaving that
+// side open.
+func inRange(t, start, end time.Time) bool {
+	if !start.IsZero() && t.Before(start) {
+		return false
+	}
+	if !end.IsZero() && !t.Before(end) {
+		return false
+	}
+	return true
 }
 
 type businessesResponse struct {
@@ -168,18 +248,32 @@ type businessesResponse struct {
 	Err string `json:"err,omitempty"`
 }
 
-type menuItemsRequest struct {
-	S string `json:"s"`
+func makeBusinessesEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		req := request.(BusinessesRequest)
+		v, err := svc.Businesses(req)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
 }
 
-type menuItemsResponse struct {
-	V int `json:"v"`
+func makeChecksEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		req := request.(BusinessesRequest)
+		v, err := svc.Checks(req)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
 }
 
-func makeBusinessesEndpoint(svc MockPOSService) endpoint.Endpoint {
+func makeEmployeesEndpoint(svc MockPOSService) endpoint.Endpoint {
 	return func(_ context.Context, request interface{}) (interface{}, error) {
 		req := request.(BusinessesRequest)
-		v, err := svc.Businesses(req)
+		v, err := svc.Employees(req)
 		if err != nil {
 			return nil, err
 		}
@@ -187,53 +281,328 @@ func makeBusinessesEndpoint(svc MockPOSService) endpoint.Endpoint {
 	}
 }
 
-func makeMenuItemsEndpoint(svc MockPOSService) endpoint.Endpoint {
+func makeLaborEntriesEndpoint(svc MockPOSService) endpoint.Endpoint {
 	return func(_ context.Context, request interface{}) (interface{}, error) {
-		req := request.(menuItemsRequest)
-		v := svc.MenuItems(req.S)
-		return menuItemsResponse{v}, nil
+		req := request.(BusinessesRequest)
+		v, err := svc.LaborEntries(req)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
 	}
 }
 
-func init() {
-	CreateDB()
+func makeOrderedItemsEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		req := request.(BusinessesRequest)
+		v, err := svc.OrderedItems(req)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+func makeMenuItemsEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		req := request.(BusinessesRequest)
+		v, err := svc.MenuItems(req)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
 }
 
 func main() {
+	var (
+		keyringFile= flag.String("master-key", "", "Keyring file of the master keys business name data keys are wrapped with, shared with ReportingService; the last key is current (required; devkeys.sh makes development ones)")
+		nameHashKeyFile= flag.String("name-hash-key", "", "File of the HMAC key of business name hashes (required; devkeys.sh makes a development one)")
+		storage= flag.String("store", storeMemory, "Storage of the POS records: memory, postgres (the -db database, migrated on start) or file (the -db-file bbolt file)")
+		dsn= flag.String("db", "postgres://localhost/mockpos?sslmode=disable", "PostgreSQL connection string of -store postgres")
+		dbFile= flag.String("db-file", "mockpos.db", "bbolt file of -store file, created if missing")
+		generateFile= flag.String("generate", "", "JSON file of the synthetic dataset to seed an empty store with, in place of businessID1 alone; {} for the defaults")
+		scenarioFile= flag.String("scenario", "", "YAML or JSON scenario file of the records to seed an empty store with, after those of -generate, in place of businessID1 alone")
+		adminToken= flag.String("admin-token", "", "Bearer token of the /admin endpoints, which are not served without one")
+		traceExporter= flag.String("trace", tracing.None, "Trace exporter: none, stdout (spans printed as JSON) or otlp (OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT, default localhost:4318)")
+	)
+	flag.Parse()
+
 	logger := log.NewLogfmtLogger(os.Stderr)
+
+	if err := tracing.Setup("mock-pos", *traceExporter); err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+
+	if *keyringFile == "" {
+		logger.Log("err", "-master-key is required")
+		os.Exit(1)
+	}
+	if *nameHashKeyFile == "" {
+		logger.Log("err", "-name-hash-key is required")
+		os.Exit(1)
+	}
+	keys, err := loadKeyring(*keyringFile)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	hashKey, err := loadNameHashKey(*nameHashKeyFile)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	env := envelope{keys, hashKey}
+	db, err := openStorage(*storage, *dsn, *dbFile)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	businesses, err := db.GetAllBusinesses()
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	// a database is seeded once, on its first start
+	if len(businesses) == 0 {
+		seed, err := seedSnapshot(env, *generateFile, *scenarioFile)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		if err := db.Restore(seed); err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		logger.Log("msg", "seeded", "businesses", len(seed.Businesses), "checks", len(seed.Checks), "ordered_items", len(seed.OrderedItems))
+	}
+
 	var svc MockPOSService
-	svc = mockPOSService{}
+	svc = mockPOSService{env, db}
 	svc = loggingMiddleware{logger, svc}
 
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(tracing.StartServerSpan(otel.Tracer("mock-pos"))),
+		httptransport.ServerFinalizer(tracing.EndServerSpan),
+	}
+
 	businessesHandler := httptransport.NewServer(
 		makeBusinessesEndpoint(svc),
 		decodeBusinessesRequest,
 		encodeResponse,
+		options...,
+	)
+
+	checksHandler := httptransport.NewServer(
+		makeChecksEndpoint(svc),
+		decodeBusinessesRequest,
+		encodeResponse,
+		options...,
+	)
+
+	employeesHandler := httptransport.NewServer(
+		makeEmployeesEndpoint(svc),
+		decodeBusinessesRequest,
+		encodeResponse,
+		options...,
+	)
+
+	laborEntriesHandler := httptransport.NewServer(
+		makeLaborEntriesEndpoint(svc),
+		decodeBusinessesRequest,
+		encodeResponse,
+		options...,
 	)
 
 	menuItemsHandler := httptransport.NewServer(
 		makeMenuItemsEndpoint(svc),
-		decodeMenuItemsRequest,
+		decodeBusinessesRequest,
+		encodeResponse,
+		options...,
+	)
+
+	orderedItemsHandler := httptransport.NewServer(
+		makeOrderedItemsEndpoint(svc),
+		decodeBusinessesRequest,
+		encodeResponse,
+		options...,
+	)
+
+	createCheckHandler := httptransport.NewServer(
+		makeCreateCheckEndpoint(svc),
+		decodeCheckRequest,
+		encodeResponse,
+		options...,
+	)
+
+	updateCheckHandler := httptransport.NewServer(
+		makeUpdateCheckEndpoint(svc),
+		decodeCheckRequest,
+		encodeResponse,
+		options...,
+	)
+
+	deleteCheckHandler := httptransport.NewServer(
+		makeDeleteCheckEndpoint(svc),
+		decodeIDRequest,
+		encodeResponse,
+		options...,
+	)
+
+	createEmployeeHandler := httptransport.NewServer(
+		makeCreateEmployeeEndpoint(svc),
+		decodeEmployeeRequest,
+		encodeResponse,
+		options...,
+	)
+
+	updateEmployeeHandler := httptransport.NewServer(
+		makeUpdateEmployeeEndpoint(svc),
+		decodeEmployeeRequest,
+		encodeResponse,
+		options...,
+	)
+
+	deleteEmployeeHandler := httptransport.NewServer(
+		makeDeleteEmployeeEndpoint(svc),
+		decodeIDRequest,
+		encodeResponse,
+		options...,
+	)
+
+	createMenuItemHandler := httptransport.NewServer(
+		makeCreateMenuItemEndpoint(svc),
+		decodeMenuItemRequest,
+		encodeResponse,
+		options...,
+	)
+
+	updateMenuItemHandler := httptransport.NewServer(
+		makeUpdateMenuItemEndpoint(svc),
+		decodeMenuItemRequest,
 		encodeResponse,
+		options...,
+	)
+
+	deleteMenuItemHandler := httptransport.NewServer(
+		makeDeleteMenuItemEndpoint(svc),
+		decodeIDRequest,
+		encodeResponse,
+		options...,
+	)
+
+	rewrapHandler := httptransport.NewServer(
+		makeRewrapBusinessesEndpoint(svc),
+		decodeEmptyRequest,
+		encodeResponse,
+		options...,
+	)
+
+	snapshotHandler := httptransport.NewServer(
+		makeSnapshotEndpoint(svc),
+		decodeEmptyRequest,
+		encodeResponse,
+		options...,
+	)
+
+	restoreHandler := httptransport.NewServer(
+		makeRestoreEndpoint(svc),
+		decodeSnapshotRequest,
+		encodeResponse,
+		options...,
+	)
+
+	scenarioHandler := httptransport.NewServer(
+		makeLoadScenarioEndpoint(svc),
+		decodeScenarioRequest,
+		encodeResponse,
+		options...,
 	)
 
 	http.Handle("/businesses", businessesHandler)
-	http.Handle("/menuItems", menuItemsHandler)
+	http.Handle("/checks", methods{"GET": checksHandler, "POST": createCheckHandler})
+	http.Handle("/checks/", methods{"PUT": updateCheckHandler, "DELETE": deleteCheckHandler})
+	http.Handle("/employees", methods{"GET": employeesHandler, "POST": createEmployeeHandler})
+	http.Handle("/employees/", methods{"PUT": updateEmployeeHandler, "DELETE": deleteEmployeeHandler})
+	http.Handle("/labor_entries", laborEntriesHandler)
+	http.Handle("/menu_items", methods{"GET": menuItemsHandler, "POST": createMenuItemHandler})
+	http.Handle("/menu_items/", methods{"PUT": updateMenuItemHandler, "DELETE": deleteMenuItemHandler})
+	http.Handle("/ordered_items", orderedItemsHandler)
+	if *adminToken != "" {
+		http.Handle("/admin/rewrap", adminAuth{*adminToken, methods{"POST": rewrapHandler}})
+		http.Handle("/admin/snapshot", adminAuth{*adminToken, methods{"GET": snapshotHandler}})
+		http.Handle("/admin/restore", adminAuth{*adminToken, methods{"POST": restoreHandler}})
+		http.Handle("/admin/scenario", adminAuth{*adminToken, methods{"POST": scenarioHandler}})
+	} else {
+		logger.Log("msg", "no -admin-token, /admin endpoints disabled")
+	}
 	http.ListenAndServe(":8091", nil)
 }
 
+//limit (number) - The amount of results to return is 100 by default and and the max is 500.
+//offset (number) - The amount of results to skip the default is 0.
+//business_id (uuid) - The business_id of record used to constrain the results.
+//name (string) - The name /businesses are looked up by when no business_id is given.
+//updated_start, updated_end, created_start, created_end (RFC3339) - The date ranges of record used to constrain the results.
+//clocked_start, clocked_end (RFC3339) - The range the shifts of /labor_entries overlap, shifts still clocked in running on.
+const (
+	defaultLimit = 100
+	maxLimit     = 500
+)
+
+var (
+	errBadLimit  = errors.New("limit must be a positive number")
+	errBadOffset = errors.New("offset must be a non-negative number")
+	errBadDate   = errors.New("date must be an RFC3339 timestamp")
+)
+
+// decodeBusinessesRequest reads limit, offset, business_id and the date
+// ranges from the query string. A limit above maxLimit is lowered to maxLimit.
 func decodeBusinessesRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request BusinessesRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+	q := r.URL.Query()
+	request := BusinessesRequest{
+		Limit:      defaultLimit,
+		Offset:     0,
+		BusinessID: q.Get("business_id"),
+		Name:       q.Get("name"),
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return nil, errBadLimit
+		}
+		request.Limit = limit
 	}
-	return request, nil
-}
-
-func decodeMenuItemsRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request menuItemsRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+	if request.Limit > maxLimit {
+		request.Limit = maxLimit
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return nil, errBadOffset
+		}
+		request.Offset = offset
+	}
+	dates := map[string]*time.Time{
+		"updated_start": &request.UpdatedStart,
+		"updated_end":   &request.UpdatedEnd,
+		"created_start": &request.CreatedStart,
+		"created_end":   &request.CreatedEnd,
+		"clocked_start": &request.ClockedStart,
+		"clocked_end":   &request.ClockedEnd,
+	}
+	for name, date := range dates {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errBadDate
+		}
+		*date = t
 	}
 	return request, nil
 }
@@ -249,7 +618,7 @@ type loggingMiddleware struct { //decorator
 	next   MockPOSService //Since our MockPOSService is defined as an interface, we just need to make a new type which wraps an existing MockPOSService, and performs the extra logging duties.
 }
 
-func (mw loggingMiddleware) Businesses (req BusinessesRequest) (output Business, err error) {
+func (mw loggingMiddleware) Businesses (req BusinessesRequest) (output EncryptedBusiness, err error) {
 	defer func(begin time.Time) {
 		callTime := time.Now().Format(TimeFormat)
 		mw.logger.Log(
@@ -268,523 +637,97 @@ func (mw loggingMiddleware) Businesses (req BusinessesRequest) (output Business,
 	return
 }
 
-func (mw loggingMiddleware) MenuItems(s string) (n int) {
+func (mw loggingMiddleware) Checks(req BusinessesRequest) (output []Check, err error) {
 	defer func(begin time.Time) {
 		callTime := time.Now().Format(TimeFormat)
 		mw.logger.Log(
 			"callTime", callTime,
-			"method", "menuItems",
-			"input", s,
-			"n", n,
+			"method", "checks",
+			"Limit", req.Limit,
+			"Offset", req.Offset,
+			"BusinessID", req.BusinessID,
+			"n", len(output),
+			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
-	n = mw.next.MenuItems(s)
+	output, err = mw.next.Checks(req)
 	return
 }
 
-func CreateDB() (error) {
-
-	businessID, err := AddBusiness()
-	if err != nil {
-		return err
-	}
-	err = AddEmployeesByBusinessID(businessID)
-	if err != nil {
-		return err
-	}
-	err = AddMenuItemsByBusinessID(businessID)
-	if err != nil {
-		return err
-	}
-
-	//AddLaborEntries
-	employee1, err := GetEmployeeByName("JohnWayne")
-	if err != nil {
-		return err
-	}
-	err = AddChecksByBusinessIDEmployeeID(businessID, employee1.ID)
-	if err != nil {
-		return err
-	}
-	err = AddLaborEntriesByBusinessIDEmployeeID(businessID, employee1.ID)
-	if err != nil {
-		return err
-	}
-	employee2, err := GetEmployeeByName("MaryPoppins")
-	if err != nil {
-		return err
-	}
-	err = AddChecksByBusinessIDEmployeeID(businessID, employee2.ID)
-	if err != nil {
-		return err
-	}
-	err = AddLaborEntriesByBusinessIDEmployeeID(businessID, employee2.ID)
-	if err != nil {
-		return err
-	}
-
-	//AddOrderedItems
-	item1, err := GetMenuItemByName("Buffalo Wing")
-	check1, err := GetCheckByName("check1")
-	AddOrderedItemsByBusinessIDEmployeeIDCheckIDItemID(businessID, employee1.ID, check1.ID, item1.ID)
-	item2, err := GetMenuItemByName("Origional Recipe")
-	AddOrderedItemsByBusinessIDEmployeeIDCheckIDItemID(businessID, employee2.ID, check1.ID, item2.ID)
-	
-	return nil
-}
-
-//func AddBusiness() (string, error) {
-//	if db != nil {
-//		txn, err := db.Begin()
-//		if err != nil {
-//			loggeFatal(erError())
-//			return "", err
-//		}
-//
-//		stmt, err := txn.Prepare(pq.CopyIn("business", "id", "name", "hours", "updated_at", "created_at")) //*database/sql.Stmt //COPY "link_test" ("url", "name") FROM STDIN
-//		if err != nil {
-//			loggeFatal(erError())
-//			return "", err
-//		}
-//
-//		business := Business{}
-//		businessID, err := uuid.NewV4()
-//		if err != nil {
-//			return "", err
-//		}
-//		business.ID = businessID.String()
-//		business.Name = "World's Best Fried Chicken"
-//		business.Hours = []int{11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22}
-//		business.UpdatedAt = time.Now()
-//		business.CreatedAt = time.Date(1900, 01, 02, 03, 04, 05, 123456789, time.UTC)
-//
-//		theBusiness = append(theBusiness, business)
-//
-//
-//		//DB
-//		_, err = stmt.Exec(business.ID, business.Name, business.Hours, business.UpdatedAt, business.CreatedAt)
-//		if err != nil {
-//			loggeFatal(erError())
-//			return "", err
-//		}
-//
-//		err = stmt.Close()
-//		if err != nil {
-//			loggeFatal(erError())
-//			return "", err
-//		}
-//
-//		err = txn.Commit()
-//		if err != nil {
-//			loggeFatal(erError())
-//			return "", err
-//		}
-//
-//		return business.ID, nil
-//	}
-//	return "", nil
-//}
-
-func AddBusiness() (string, error) {
-	business := Business{}
-	//businessID, err := uuid.NewV4()
-	//if err != nil {
-	//	return "", err
-	//}
-	//business.ID = businessID.String()
-	business.ID = "businessID1"
-	business.Name = "World's Best Fried Chicken"
-	business.Hours = []int{11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22}
-	business.UpdatedAt = time.Now()
-	business.CreatedAt = time.Date(1900, 01, 02, 03, 04, 05, 123456789, time.UTC)
-
-	theBusiness = append(theBusiness, business)
-	return "", nil
-}
-
-func GetBusinessByID(id string) (Business, error) {
-	for _, entity := range theBusiness {
-		if entity.ID == id {
-			return entity, nil
-		}
-	}
-	return Business{}, nil
-}
-
-func AddEmployeesByBusinessID(businessID string) (err error) {
-	employee1 := Employee{}
-	uuid1, err := uuid.NewV4()
-	if err == nil {
-		employee1.ID = uuid1.String()
-	}
-	employee1.BusinessID = businessID
-	employee1.FirstName = "John"
-	employee1.LastName = "Wayne"
-	employee1.PayRate = inf.NewDec(2500, 2)
-	employee1.UpdatedAt = time.Now()
-	employee1.CreatedAt = time.Date(2015, 12, 21, 05, 34, 58, 651387237, time.UTC)
-
-	theEmployee = append(theEmployee, employee1)
-	AddChecksByBusinessIDEmployeeID(businessID, employee1.ID)
-	AddLaborEntriesByBusinessIDEmployeeID(businessID, employee1.ID)
-
-	employee2 := Employee{}
-	uuid2, err := uuid.NewV4()
-	if err == nil {
-		employee2.ID = uuid2.String()
-	}
-	employee2.BusinessID = businessID
-	employee2.FirstName = "Mary"
-	employee2.LastName = "Poppins"
-	employee2.PayRate = inf.NewDec(2100, 2)
-	employee2.UpdatedAt = time.Now()
-	employee2.CreatedAt = time.Date(2017, 01, 01, 05, 34, 58, 651387237, time.UTC)
-
-	theEmployee = append(theEmployee, employee2)
-	AddChecksByBusinessIDEmployeeID(businessID, employee2.ID)
-	AddLaborEntriesByBusinessIDEmployeeID(businessID, employee2.ID)
-
-	return nil
-}
-
-func GetEmployeeByID (employeeID string) (Employee, error) {
-	for _, emp := range theEmployee {
-		if emp.ID == employeeID {
-			return emp, nil
-		}
-	}
-	return Employee{}, nil
-}
-
-func GetEmployeeByName(name string) (Employee, error) {
-	for _, entity := range theEmployee {
-		if entity.FirstName + entity.LastName == name {
-			return entity, nil
-		}
-	}
-	return Employee{}, nil
-}
-
-func GetEmployeesByBusinessID(businessID string) ([]Employee, error) {
-	items := make([]Employee, 0)
-	for _, entity := range theEmployee {
-		if entity.BusinessID == businessID {
-			items = append(items, entity)
-		}
-	}
-	return items, nil
-}
-
-func GetAllEmployees() ([]Employee, error) {
-	return theEmployee, nil
-}
-
-func CreateMenuItem(m MenuItem) (string, error) {
-	theMenuItem = append(theMenuItem, m)
-	return m.ID, nil
-}
-
-func AddMenuItemsByBusinessID(businessID string) (err error) {
-	menuitem1 := MenuItem{}
-	uuid1, err := uuid.NewV4()
-	if err == nil {
-		menuitem1.ID = uuid1.String()
-	}
-	menuitem1.BusinessID = businessID
-	menuitem1.Name = "Buffalo Wing"
-	menuitem1.Cost = inf.NewDec(1000, 2)
-	menuitem1.Price = inf.NewDec(1500, 2)
-	menuitem1.UpdatedAt = time.Now()
-	menuitem1.CreatedAt = time.Date(2015, 12, 21, 05, 34, 58, 651387237, time.UTC)
-
-	theMenuItem = append(theMenuItem, menuitem1)
-
-	menuitem2 := MenuItem{}
-	uuid2, err := uuid.NewV4()
-	if err == nil {
-		menuitem2.ID = uuid2.String()
-	}
-	menuitem2.BusinessID = businessID
-	menuitem2.Name = "Origional Recipe"
-	menuitem2.Cost = inf.NewDec(1000, 2)
-	menuitem2.Price = inf.NewDec(1500, 2)
-	menuitem2.UpdatedAt = time.Now()
-	menuitem2.CreatedAt = time.Date(2017, 01, 01, 05, 34, 58, 651387237, time.UTC)
-
-	theMenuItem = append(theMenuItem, menuitem2)
-
-	return nil
-}
-
-func GetMenuItemByID(id string) (MenuItem, error) {
-	for _, entity := range theMenuItem {
-		if entity.ID == id {
-			return entity, nil
-		}
-	}
-	return MenuItem{}, nil
-}
-
-func GetMenuItemByName(name string) (MenuItem, error) {
-	for _, entity := range theMenuItem {
-		if entity.Name == name {
-			return entity, nil
-		}
-	}
-	return MenuItem{}, nil
-}
-
-func GetMenuItemsByBusinessID(businessID string) ([]MenuItem, error) {
-	items := make([]MenuItem, 0)
-	for _, entity := range theMenuItem {
-		if entity.BusinessID == businessID {
-			items = append(items, entity)
-		}
-	}
-	return items, nil
-}
-
-func GetAllMenuItems() ([]MenuItem, error) {
-	return theMenuItem, nil
-}
-
-func DeleteMenuItemByID(id string) error {
-	index := -1
-	for i, entity := range theMenuItem {
-		if entity.ID == id {
-			index = i
-			break
-		}
-	}
-	if index > -1 {
-		theMenuItem = append(theMenuItem[:index], theMenuItem[index+1:]...)
-	}
-	return nil
-}
-
-func UpdateMenuItemByID(m MenuItem, id string) (string, error) {
-	index := -1
-	for i, entity := range theMenuItem {
-		if entity.ID == id {
-			index = i
-			break
-		}
-	}
-	if index > -1 {
-		theMenuItem = append(theMenuItem[:index], theMenuItem[index+1:]...)
-	}
-	theMenuItem = append(theMenuItem, m)
-	return m.ID, nil
-}
-
-func GetMenuItemsByStartEndDate(start, end time.Time) ([]MenuItem, error) {
-	items := make([]MenuItem, 0)
-	for _, entity := range theMenuItem {
-		if entity.UpdatedAt.After(start) && entity.UpdatedAt.Before(end) {
-			items = append(items, entity)
-		}
-	}
-	return items, nil
-}
-
-func GetMenuItemsByBusinessIDStartEndDate(businessID string, start, end time.Time) ([]MenuItem, error) {
-	items := make([]MenuItem, 0)
-	for _, entity := range theMenuItem {
-		if entity.BusinessID == businessID && entity.UpdatedAt.After(start) && entity.UpdatedAt.Before(end) {
-			items = append(items, entity)
-		}
-	}
-	return items, nil
-}
-
-func AddChecksByBusinessIDEmployeeID(businessID, employeeID string) (err error) {
-	check1 := Check{}
-	uudi1, err := uuid.NewV4()
-	if err == nil {
-		check1.ID = uudi1.String()
-	}
-	check1.BusinessID = businessID
-	check1.EmployeeID = employeeID
-	check1.Name = "check1"
-	check1.Closed = true
-	check1.ClosedAt = time.Date(2018, 12, 23, 05, 06, 07, 123456789, time.UTC)
-	check1.UpdatedAt = time.Now()
-	check1.CreatedAt = time.Date(2015, 12, 21, 05, 34, 58, 651387237, time.UTC)
-
-	theCheck = append(theCheck, check1)
-
-	check2 := Check{}
-	uudi2, err := uuid.NewV4()
-	if err == nil {
-		check2.ID = uudi2.String()
-	}
-	check2.BusinessID = businessID
-	check2.EmployeeID = employeeID
-	check2.Name = "check2"
-	check2.Closed = false
-	check2.UpdatedAt = time.Now()
-	check2.CreatedAt = time.Date(2017, 01, 01, 05, 34, 58, 651387237, time.UTC)
-
-	theCheck = append(theCheck, check2)
-
-	return nil
-}
-
-func GetCheckByName(name string) (Check, error) {
-	for _, entity := range theCheck {
-		if entity.Name == name {
-			return entity, nil
-		}
-	}
-	return Check{}, nil
-}
+func (mw loggingMiddleware) Employees(req BusinessesRequest) (output []Employee, err error) {
+	defer func(begin time.Time) {
+		callTime := time.Now().Format(TimeFormat)
+		mw.logger.Log(
+			"callTime", callTime,
+			"method", "employees",
+			"Limit", req.Limit,
+			"Offset", req.Offset,
+			"BusinessID", req.BusinessID,
+			"n", len(output),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
 
-func GetAllChecks() ([]Check, error) {
-	entities := []Check{}
-	for _, entity := range theCheck {
-		entities = append(entities, entity)
-	}
-	return entities, nil
+	output, err = mw.next.Employees(req)
+	return
 }
 
-func AddLaborEntriesByBusinessIDEmployeeID(businessID, employeeID string) (err error) {
-	laborEntry1 := LaborEntry{}
-	uudi1, err := uuid.NewV4()
-	if err == nil {
-		laborEntry1.ID = uudi1.String()
-	}
-	laborEntry1.BusinessID = businessID
-	laborEntry1.EmployeeID = employeeID
-	laborEntry1.Name = "laborEntry1"
-	laborEntry1.ClockIn = time.Now()
-	laborEntry1.ClockOut = time.Now().Add(time.Hour * 8)
-	laborEntry1.PayRate = inf.NewDec(2500, 2)
-	laborEntry1.UpdatedAt = time.Now()
-	laborEntry1.CreatedAt = time.Now()
-
-	theLaborEntry = append(theLaborEntry, laborEntry1)
-
-	laborEntry2 := LaborEntry{}
-	uudi2, err := uuid.NewV4()
-	if err == nil {
-		laborEntry2.ID = uudi2.String()
-	}
-	laborEntry2.BusinessID = businessID
-	laborEntry2.EmployeeID = employeeID
-	laborEntry2.Name = "laborEntry2"
-	laborEntry2.ClockIn = time.Now()
-	laborEntry2.ClockOut = time.Now().Add(time.Hour * 8)
-	laborEntry2.PayRate = inf.NewDec(2100, 2)
-	laborEntry2.UpdatedAt = time.Now()
-	laborEntry2.CreatedAt = time.Now()
-
-	theLaborEntry = append(theLaborEntry, laborEntry2)
+func (mw loggingMiddleware) LaborEntries(req BusinessesRequest) (output []LaborEntry, err error) {
+	defer func(begin time.Time) {
+		callTime := time.Now().Format(TimeFormat)
+		mw.logger.Log(
+			"callTime", callTime,
+			"method", "laborEntries",
+			"Limit", req.Limit,
+			"Offset", req.Offset,
+			"BusinessID", req.BusinessID,
+			"n", len(output),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
 
-	return nil
+	output, err = mw.next.LaborEntries(req)
+	return
 }
 
-func GetAllLaborEntries() ([]LaborEntry, error) {
-	entities := []LaborEntry{}
-	for _, entity := range theLaborEntry {
-		entities = append(entities, entity)
-	}
-	return entities, nil
-}
+func (mw loggingMiddleware) MenuItems(req BusinessesRequest) (output []MenuItem, err error) {
+	defer func(begin time.Time) {
+		callTime := time.Now().Format(TimeFormat)
+		mw.logger.Log(
+			"callTime", callTime,
+			"method", "menuItems",
+			"Limit", req.Limit,
+			"Offset", req.Offset,
+			"BusinessID", req.BusinessID,
+			"n", len(output),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
 
-func AddOrderedItemsByBusinessIDEmployeeIDCheckIDItemID(businessID, employeeID,
-checkID, itemID string) (err error) {
-	orderedItem1 := OrderedItem{}
-	uudi1, err := uuid.NewV4()
-	if err == nil {
-		orderedItem1.ID = uudi1.String()
-	}
-	orderedItem1.BusinessID = businessID
-	orderedItem1.EmployeeID = employeeID
-	orderedItem1.CheckID = checkID
-	orderedItem1.ItemID = itemID
-	orderedItem1.Name = "orderedItem1"
-	orderedItem1.Cost = inf.NewDec(1000, 2)
-	orderedItem1.Price = inf.NewDec(1500, 2)
-	orderedItem1.Voided = false
-	orderedItem1.UpdatedAt = time.Now()
-	orderedItem1.CreatedAt = time.Now()
-
-	theOrderedItem = append(theOrderedItem, orderedItem1)
-
-	orderedItem2 := OrderedItem{}
-	uudi2, err := uuid.NewV4()
-	if err == nil {
-		orderedItem2.ID = uudi2.String()
-	}
-	orderedItem2.BusinessID = businessID
-	orderedItem2.EmployeeID = employeeID
-	orderedItem2.CheckID = checkID
-	orderedItem2.ItemID = itemID
-	orderedItem2.Name = "orderedItem2"
-	orderedItem2.Cost = inf.NewDec(1000, 2)
-	orderedItem2.Price = inf.NewDec(1500, 2)
-	orderedItem2.Voided = true
-	orderedItem2.UpdatedAt = time.Now()
-	orderedItem2.CreatedAt = time.Now()
-
-	theOrderedItem = append(theOrderedItem, orderedItem2)
-
-	return nil
-}
-
-func GetAllOrderedItems() ([]OrderedItem, error) {
-	entities := []OrderedItem{}
-	for _, entity := range theOrderedItem {
-		entities = append(entities, entity)
-	}
-	return entities, nil
+	output, err = mw.next.MenuItems(req)
+	return
 }
 
-func GetAllEntities() ([]interface{}, error) {
-
-	var entities []interface{}
-
-	bus, err := GetAllBusinesses()
-	if err != nil {
-		return nil, err
-	}
-	entities = append(entities, bus)
-
-	emp, err := GetAllEmployees()
-	if err != nil {
-		return nil, err
-	}
-	entities = append(entities, emp)
-
-	menu, err := GetAllMenuItems()
-	if err != nil {
-		return nil, err
-	}
-	entities = append(entities, menu)
-
-	check, err := GetAllChecks()
-	if err != nil {
-		return nil, err
-	}
-	entities = append(entities, check)
-
-	labor, err := GetAllLaborEntries()
-	if err != nil {
-		return nil, err
-	}
-	entities = append(entities, labor)
-
-	order, err := GetAllOrderedItems()
-	if err != nil {
-		return nil, err
-	}
-	entities = append(entities, order)
+func (mw loggingMiddleware) OrderedItems(req BusinessesRequest) (output []OrderedItem, err error) {
+	defer func(begin time.Time) {
+		callTime := time.Now().Format(TimeFormat)
+		mw.logger.Log(
+			"callTime", callTime,
+			"method", "orderedItems",
+			"Limit", req.Limit,
+			"Offset", req.Offset,
+			"BusinessID", req.BusinessID,
+			"n", len(output),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
 
-	return entities, nil
+	output, err = mw.next.OrderedItems(req)
+	return
 }
-
-func GetAllBusinesses() ([]Business, error) {
-	return theBusiness, nil
-}
\ No newline at end of file
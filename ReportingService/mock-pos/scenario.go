@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/satori/go.uuid"
+	"gopkg.in/inf.v0"
+	"gopkg.in/yaml.v2"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// seedScenario is the dataset an empty store is seeded with by default:
+// businessID1 and its records.
+//
+//go:embed scenarios/seed.yaml
+var seedScenario []byte
+
+// scenario is a hand-made dataset, e.g. of edge cases, in YAML or JSON.
+// Records name each other by ref, a name of the record within the file, and
+// get IDs derived from it, which stay the same each time the file is loaded.
+// A record may set its id instead. Times are RFC3339 and amounts of money
+// strings such as "12.50"; fields left out default as described.
+type scenario struct {
+	Businesses   []scenarioBusiness    `yaml:"businesses"`
+	Employees    []scenarioEmployee    `yaml:"employees"`
+	MenuItems    []scenarioMenuItem    `yaml:"menu_items"`
+	Checks       []scenarioCheck       `yaml:"checks"`
+	OrderedItems []scenarioOrderedItem `yaml:"ordered_items"`
+	LaborEntries []scenarioLaborEntry  `yaml:"labor_entries"`
+}
+
+type scenarioBusiness struct {
+	Ref       string `yaml:"ref"`
+	ID        string `yaml:"id"`
+	Name      string `yaml:"name"`
+	Hours     []int  `yaml:"hours"`
+	TimeZone  string `yaml:"time_zone"`
+	CreatedAt string `yaml:"created_at"`
+}
+
+type scenarioEmployee struct {
+	Ref       string `yaml:"ref"`
+	ID        string `yaml:"id"`
+	Business  string `yaml:"business"`
+	FirstName string `yaml:"first_name"`
+	LastName  string `yaml:"last_name"`
+	PayRate   string `yaml:"pay_rate"`   // the current one; labor entries may have others
+	CreatedAt string `yaml:"created_at"` // default that of the business
+}
+
+type scenarioMenuItem struct {
+	Ref       string `yaml:"ref"`
+	ID        string `yaml:"id"`
+	Business  string `yaml:"business"`
+	Name      string `yaml:"name"`
+	Cost      string `yaml:"cost"`
+	Price     string `yaml:"price"`      // the current one; ordered items may have others
+	CreatedAt string `yaml:"created_at"` // default that of the business
+}
+
+type scenarioCheck struct {
+	Ref       string `yaml:"ref"`
+	ID        string `yaml:"id"`
+	Business  string `yaml:"business"`
+	Employee  string `yaml:"employee"` // none if left out
+	Name      string `yaml:"name"`     // default the ref
+	CreatedAt string `yaml:"created_at"`
+	ClosedAt  string `yaml:"closed_at"` // the check is open if left out
+}
+
+type scenarioOrderedItem struct {
+	ID        string `yaml:"id"`
+	Check     string `yaml:"check"`
+	Item      string `yaml:"item"`
+	Employee  string `yaml:"employee"` // default that of the check
+	Name      string `yaml:"name"`     // default that of the item
+	Cost      string `yaml:"cost"`     // default that of the item
+	Price     string `yaml:"price"`    // default that of the item
+	Voided    bool   `yaml:"voided"`
+	CreatedAt string `yaml:"created_at"` // default that of the check
+}
+
+type scenarioLaborEntry struct {
+	ID       string `yaml:"id"`
+	Employee string `yaml:"employee"`
+	Name     string `yaml:"name"`
+	ClockIn  string `yaml:"clock_in"`
+	ClockOut string `yaml:"clock_out"` // still clocked in if left out
+	PayRate  string `yaml:"pay_rate"`  // default that of the employee, which must have one then
+}
+
+// scenarioError is a scenario that cannot be parsed or whose records do not
+// add up, answered with 400.
+type scenarioError struct {
+	msg string
+}
+
+func (e scenarioError) Error() string {
+	return "scenario: " + e.msg
+}
+
+func scenarioErrorf(format string, args ...interface{}) error {
+	return scenarioError{fmt.Sprintf(format, args...)}
+}
+
+// parseScenario reads a scenario in YAML, or JSON, which is YAML too. Fields
+// it does not know are errors, so typos do not go unnoticed.
+func parseScenario(data []byte) (scenario, error) {
+	var sc scenario
+	if err := yaml.UnmarshalStrict(data, &sc); err != nil {
+		return scenario{}, scenarioError{err.Error()}
+	}
+	return sc, nil
+}
+
+func loadScenario(path string) (scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return scenario{}, err
+	}
+	sc, err := parseScenario(data)
+	if err != nil {
+		return scenario{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return sc, nil
+}
+
+// scenarioID is the id of a record, or else the one derived from its ref, or
+// else from its place in the file.
+func scenarioID(collection, id, ref string, i int) string {
+	if id != "" {
+		return id
+	}
+	if ref == "" {
+		ref = fmt.Sprintf("#%d", i)
+	}
+	return uuid.NewV5(uuid.NamespaceURL, "mock-pos:"+collection+"/"+ref).String()
+}
+
+// scenarioTime parses the RFC3339 time s, or returns otherwise if s is empty.
+func scenarioTime(s string, otherwise time.Time) (time.Time, error) {
+	if s == "" {
+		return otherwise, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// scenarioMoney parses the amount s, or returns otherwise if s is empty.
+func scenarioMoney(s string, otherwise *inf.Dec) (*inf.Dec, error) {
+	if s == "" {
+		return otherwise, nil
+	}
+	d, ok := new(inf.Dec).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("bad amount %q", s)
+	}
+	return d, nil
+}
+
+func latest(times ...time.Time) time.Time {
+	var last time.Time
+	for _, t := range times {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// snapshot resolves the refs of the scenario and returns its records, with
+// the business names encrypted by env.
+func (sc scenario) snapshot(env envelope) (Snapshot, error) {
+	snapshot := Snapshot{
+		Businesses:   make([]EncryptedBusiness, 0, len(sc.Businesses)),
+		Checks:       make([]Check, 0, len(sc.Checks)),
+		Employees:    make([]Employee, 0, len(sc.Employees)),
+		LaborEntries: make([]LaborEntry, 0, len(sc.LaborEntries)),
+		MenuItems:    make([]MenuItem, 0, len(sc.MenuItems)),
+		OrderedItems: make([]OrderedItem, 0, len(sc.OrderedItems)),
+	}
+
+	businesses := make(map[string]Business)
+	for i, b := range sc.Businesses {
+		where := fmt.Sprintf("businesses[%d]", i)
+		if _, ok := businesses[b.Ref]; ok || b.Ref == "" {
+			return Snapshot{}, scenarioErrorf("%s: missing or duplicate ref %q", where, b.Ref)
+		}
+		if _, err := time.LoadLocation(b.TimeZone); err != nil || b.TimeZone == "" {
+			return Snapshot{}, scenarioErrorf("%s: bad time_zone %q", where, b.TimeZone)
+		}
+		created, err := scenarioTime(b.CreatedAt, time.Time{})
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: created_at: %v", where, err)
+		}
+		business := Business{
+			ID:        scenarioID("businesses", b.ID, b.Ref, i),
+			Name:      b.Name,
+			Hours:     b.Hours,
+			TimeZone:  b.TimeZone,
+			UpdatedAt: created,
+			CreatedAt: created,
+		}
+		encrypted, err := env.encryptBusiness(business)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		businesses[b.Ref] = business
+		snapshot.Businesses = append(snapshot.Businesses, encrypted)
+	}
+
+	employees := make(map[string]Employee)
+	for i, e := range sc.Employees {
+		where := fmt.Sprintf("employees[%d]", i)
+		if _, ok := employees[e.Ref]; ok || e.Ref == "" {
+			return Snapshot{}, scenarioErrorf("%s: missing or duplicate ref %q", where, e.Ref)
+		}
+		business, ok := businesses[e.Business]
+		if !ok {
+			return Snapshot{}, scenarioErrorf("%s: unknown business %q", where, e.Business)
+		}
+		payRate, err := scenarioMoney(e.PayRate, nil)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: pay_rate: %v", where, err)
+		}
+		created, err := scenarioTime(e.CreatedAt, business.CreatedAt)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: created_at: %v", where, err)
+		}
+		employee := Employee{
+			ID:         scenarioID("employees", e.ID, e.Ref, i),
+			BusinessID: business.ID,
+			FirstName:  e.FirstName,
+			LastName:   e.LastName,
+			PayRate:    payRate,
+			UpdatedAt:  created,
+			CreatedAt:  created,
+		}
+		employees[e.Ref] = employee
+		snapshot.Employees = append(snapshot.Employees, employee)
+	}
+
+	menuItems := make(map[string]MenuItem)
+	for i, m := range sc.MenuItems {
+		where := fmt.Sprintf("menu_items[%d]", i)
+		if _, ok := menuItems[m.Ref]; ok || m.Ref == "" {
+			return Snapshot{}, scenarioErrorf("%s: missing or duplicate ref %q", where, m.Ref)
+		}
+		business, ok := businesses[m.Business]
+		if !ok {
+			return Snapshot{}, scenarioErrorf("%s: unknown business %q", where, m.Business)
+		}
+		cost, err := scenarioMoney(m.Cost, nil)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: cost: %v", where, err)
+		}
+		price, err := scenarioMoney(m.Price, nil)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: price: %v", where, err)
+		}
+		created, err := scenarioTime(m.CreatedAt, business.CreatedAt)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: created_at: %v", where, err)
+		}
+		item := MenuItem{
+			ID:         scenarioID("menu_items", m.ID, m.Ref, i),
+			BusinessID: business.ID,
+			Name:       m.Name,
+			Cost:       cost,
+			Price:      price,
+			UpdatedAt:  created,
+			CreatedAt:  created,
+		}
+		menuItems[m.Ref] = item
+		snapshot.MenuItems = append(snapshot.MenuItems, item)
+	}
+
+	checks := make(map[string]Check)
+	for i, c := range sc.Checks {
+		where := fmt.Sprintf("checks[%d]", i)
+		if _, ok := checks[c.Ref]; ok || c.Ref == "" {
+			return Snapshot{}, scenarioErrorf("%s: missing or duplicate ref %q", where, c.Ref)
+		}
+		business, ok := businesses[c.Business]
+		if !ok {
+			return Snapshot{}, scenarioErrorf("%s: unknown business %q", where, c.Business)
+		}
+		employee, ok := employees[c.Employee]
+		if !ok && c.Employee != "" {
+			return Snapshot{}, scenarioErrorf("%s: unknown employee %q", where, c.Employee)
+		}
+		if ok && employee.BusinessID != business.ID {
+			return Snapshot{}, scenarioErrorf("%s: employee %q is not of business %q", where, c.Employee, c.Business)
+		}
+		created, err := scenarioTime(c.CreatedAt, time.Time{})
+		if err != nil || created.IsZero() {
+			return Snapshot{}, scenarioErrorf("%s: missing or bad created_at %q", where, c.CreatedAt)
+		}
+		closed, err := scenarioTime(c.ClosedAt, time.Time{})
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: closed_at: %v", where, err)
+		}
+		name := c.Name
+		if name == "" {
+			name = c.Ref
+		}
+		check := Check{
+			ID:         scenarioID("checks", c.ID, c.Ref, i),
+			BusinessID: business.ID,
+			EmployeeID: employee.ID,
+			Name:       name,
+			Closed:     !closed.IsZero(),
+			ClosedAt:   closed,
+			UpdatedAt:  latest(created, closed),
+			CreatedAt:  created,
+		}
+		checks[c.Ref] = check
+		snapshot.Checks = append(snapshot.Checks, check)
+	}
+
+	for i, o := range sc.OrderedItems {
+		where := fmt.Sprintf("ordered_items[%d]", i)
+		check, ok := checks[o.Check]
+		if !ok {
+			return Snapshot{}, scenarioErrorf("%s: unknown check %q", where, o.Check)
+		}
+		item, ok := menuItems[o.Item]
+		if !ok || item.BusinessID != check.BusinessID {
+			return Snapshot{}, scenarioErrorf("%s: unknown menu item %q of the business of check %q", where, o.Item, o.Check)
+		}
+		employeeID := check.EmployeeID
+		if o.Employee != "" {
+			employee, ok := employees[o.Employee]
+			if !ok {
+				return Snapshot{}, scenarioErrorf("%s: unknown employee %q", where, o.Employee)
+			}
+			if employee.BusinessID != check.BusinessID {
+				return Snapshot{}, scenarioErrorf("%s: employee %q is not of the business of check %q", where, o.Employee, o.Check)
+			}
+			employeeID = employee.ID
+		}
+		cost, err := scenarioMoney(o.Cost, item.Cost)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: cost: %v", where, err)
+		}
+		price, err := scenarioMoney(o.Price, item.Price)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: price: %v", where, err)
+		}
+		created, err := scenarioTime(o.CreatedAt, check.CreatedAt)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: created_at: %v", where, err)
+		}
+		name := o.Name
+		if name == "" {
+			name = item.Name
+		}
+		ordered := OrderedItem{
+			ID:         scenarioID("ordered_items", o.ID, "", i),
+			BusinessID: check.BusinessID,
+			EmployeeID: employeeID,
+			CheckID:    check.ID,
+			ItemID:     item.ID,
+			Name:       name,
+			Cost:       cost,
+			Price:      price,
+			Voided:     o.Voided,
+			UpdatedAt:  created,
+			CreatedAt:  created,
+		}
+		if o.Voided {
+			ordered.UpdatedAt = latest(created, check.ClosedAt)
+		}
+		snapshot.OrderedItems = append(snapshot.OrderedItems, ordered)
+	}
+
+	for i, l := range sc.LaborEntries {
+		where := fmt.Sprintf("labor_entries[%d]", i)
+		employee, ok := employees[l.Employee]
+		if !ok {
+			return Snapshot{}, scenarioErrorf("%s: unknown employee %q", where, l.Employee)
+		}
+		payRate, err := scenarioMoney(l.PayRate, employee.PayRate)
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: pay_rate: %v", where, err)
+		}
+		if payRate == nil {
+			return Snapshot{}, scenarioErrorf("%s: no pay_rate, nor one of employee %q", where, l.Employee)
+		}
+		clockIn, err := scenarioTime(l.ClockIn, time.Time{})
+		if err != nil || clockIn.IsZero() {
+			return Snapshot{}, scenarioErrorf("%s: missing or bad clock_in %q", where, l.ClockIn)
+		}
+		clockOut, err := scenarioTime(l.ClockOut, time.Time{})
+		if err != nil {
+			return Snapshot{}, scenarioErrorf("%s: clock_out: %v", where, err)
+		}
+		if !clockOut.IsZero() && clockOut.Before(clockIn) {
+			return Snapshot{}, scenarioErrorf("%s: clock_out before clock_in", where)
+		}
+		snapshot.LaborEntries = append(snapshot.LaborEntries, LaborEntry{
+			ID:         scenarioID("labor_entries", l.ID, "", i),
+			BusinessID: employee.BusinessID,
+			EmployeeID: employee.ID,
+			Name:       l.Name,
+			ClockIn:    clockIn,
+			ClockOut:   clockOut,
+			PayRate:    payRate,
+			UpdatedAt:  latest(clockIn, clockOut),
+			CreatedAt:  clockIn,
+		})
+	}
+	return snapshot, nil
+}
+
+// seedSnapshot is the dataset an empty store is seeded with: the one
+// generated by the generateFile config, if any, and the records of the
+// scenarioFile, or of the built-in seed scenario if neither is given.
+func seedSnapshot(env envelope, generateFile, scenarioFile string) (Snapshot, error) {
+	var seed Snapshot
+	var err error
+	if generateFile != "" {
+		config, err := loadGeneratorConfig(generateFile)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		if seed, err = generate(config, env); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	var sc scenario
+	switch {
+	case scenarioFile != "":
+		if sc, err = loadScenario(scenarioFile); err != nil {
+			return Snapshot{}, err
+		}
+	case generateFile == "":
+		if sc, err = parseScenario(seedScenario); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	records, err := sc.snapshot(env)
+	if err != nil && scenarioFile != "" {
+		return Snapshot{}, fmt.Errorf("%s: %v", scenarioFile, err)
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	seed.Businesses = append(seed.Businesses, records.Businesses...)
+	seed.Checks = append(seed.Checks, records.Checks...)
+	seed.Employees = append(seed.Employees, records.Employees...)
+	seed.LaborEntries = append(seed.LaborEntries, records.LaborEntries...)
+	seed.MenuItems = append(seed.MenuItems, records.MenuItems...)
+	seed.OrderedItems = append(seed.OrderedItems, records.OrderedItems...)
+	return seed, nil
+}
+
+// LoadScenario replaces every record with those of the scenario, as Restore
+// does with a snapshot.
+func (s mockPOSService) LoadScenario(sc scenario) (Snapshot, error) {
+	snapshot, err := sc.snapshot(s.envelope)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, s.Restore(snapshot)
+}
+
+// ScenarioResult counts the records a scenario was loaded with.
+type ScenarioResult struct {
+	Businesses   int `json:"businesses"`
+	Checks       int `json:"checks"`
+	Employees    int `json:"employees"`
+	LaborEntries int `json:"labor_entries"`
+	MenuItems    int `json:"menu_items"`
+	OrderedItems int `json:"ordered_items"`
+}
+
+func makeLoadScenarioEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		snapshot, err := svc.LoadScenario(request.(scenario))
+		if err != nil {
+			return nil, err
+		}
+		return ScenarioResult{
+			Businesses:   len(snapshot.Businesses),
+			Checks:       len(snapshot.Checks),
+			Employees:    len(snapshot.Employees),
+			LaborEntries: len(snapshot.LaborEntries),
+			MenuItems:    len(snapshot.MenuItems),
+			OrderedItems: len(snapshot.OrderedItems),
+		}, nil
+	}
+}
+
+func decodeScenarioRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, bodyError{err}
+	}
+	return parseScenario(data)
+}
+
+func (mw loggingMiddleware) LoadScenario(sc scenario) (output Snapshot, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"callTime", time.Now().Format(TimeFormat),
+			"method", "loadScenario",
+			"businesses", len(output.Businesses),
+			"checks", len(output.Checks),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.LoadScenario(sc)
+	return
+}
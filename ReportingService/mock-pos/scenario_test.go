@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testScenario = `
+businesses:
+  - {ref: diner, id: businessDiner, name: The Diner, hours: [8, 9, 10], time_zone: America/Chicago, created_at: 2018-01-01T00:00:00Z}
+  - {ref: bar, name: The Bar, hours: [20, 21], time_zone: America/New_York}
+employees:
+  - {ref: ana, business: diner, first_name: Ana, pay_rate: "18.00"}
+  - {ref: tom, business: diner, first_name: Tom, pay_rate: "16.00", created_at: 2018-02-01T00:00:00Z}
+  - {ref: kofi, business: bar, first_name: Kofi}
+menu_items:
+  - {ref: pie, business: diner, name: Pie, cost: "1.00", price: "4.00"}
+  - {ref: beer, business: bar, name: Beer, cost: "1.50", price: "7.00"}
+checks:
+  - {ref: lunch, business: diner, employee: ana, created_at: 2018-11-12T18:00:00Z, closed_at: 2018-11-12T18:30:00Z}
+  - {ref: open, business: bar, created_at: 2018-11-13T02:00:00Z}
+ordered_items:
+  - {check: lunch, item: pie}
+  - {check: lunch, item: pie, employee: tom, price: "3.50", voided: true}
+  - {check: open, item: beer}
+labor_entries:
+  - {employee: ana, name: Server, clock_in: 2018-11-12T14:00:00Z, clock_out: 2018-11-12T22:00:00Z}
+  - {employee: kofi, name: Bartender, clock_in: 2018-11-13T01:00:00Z, pay_rate: "15.00"}
+`
+
+func TestScenarioRefs(t *testing.T) {
+	sc, err := parseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := sc.snapshot(testEnvelope(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diner, bar := s.Businesses[0], s.Businesses[1]
+	ana, tom, kofi := s.Employees[0], s.Employees[1], s.Employees[2]
+	pie := s.MenuItems[0]
+	lunch, open := s.Checks[0], s.Checks[1]
+
+	if diner.ID != "businessDiner" || bar.ID != scenarioID("businesses", "", "bar", 1) {
+		t.Errorf("business IDs = %s, %s", diner.ID, bar.ID)
+	}
+	if ana.BusinessID != diner.ID || kofi.BusinessID != bar.ID || !ana.CreatedAt.Equal(diner.CreatedAt) {
+		t.Errorf("ana = %+v, kofi = %+v", ana, kofi)
+	}
+	if lunch.EmployeeID != ana.ID || !lunch.Closed || lunch.Name != "lunch" || !lunch.UpdatedAt.Equal(lunch.ClosedAt) {
+		t.Errorf("lunch = %+v", lunch)
+	}
+	if open.EmployeeID != "" || open.Closed || open.BusinessID != bar.ID {
+		t.Errorf("open = %+v", open)
+	}
+
+	sold, voided, beer := s.OrderedItems[0], s.OrderedItems[1], s.OrderedItems[2]
+	if sold.CheckID != lunch.ID || sold.ItemID != pie.ID || sold.EmployeeID != ana.ID || sold.Name != "Pie" ||
+		sold.Price.String() != "4.00" || sold.Cost.String() != "1.00" || !sold.CreatedAt.Equal(lunch.CreatedAt) {
+		t.Errorf("sold = %+v", sold)
+	}
+	if voided.EmployeeID != tom.ID || voided.Price.String() != "3.50" || !voided.Voided || !voided.UpdatedAt.Equal(lunch.ClosedAt) {
+		t.Errorf("voided = %+v", voided)
+	}
+	if beer.BusinessID != bar.ID || beer.EmployeeID != "" {
+		t.Errorf("beer = %+v", beer)
+	}
+
+	shift, clockedIn := s.LaborEntries[0], s.LaborEntries[1]
+	if shift.EmployeeID != ana.ID || shift.BusinessID != diner.ID || shift.PayRate.String() != "18.00" || !shift.UpdatedAt.Equal(shift.ClockOut) {
+		t.Errorf("shift = %+v", shift)
+	}
+	if clockedIn.PayRate.String() != "15.00" || !clockedIn.ClockOut.IsZero() {
+		t.Errorf("clocked in = %+v", clockedIn)
+	}
+
+	// loading the file again gives the records the same IDs
+	again, err := sc.snapshot(testEnvelope(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range s.OrderedItems {
+		if again.OrderedItems[i].ID != s.OrderedItems[i].ID || again.OrderedItems[i].CheckID != s.OrderedItems[i].CheckID {
+			t.Errorf("ordered item %d: %+v then %+v", i, s.OrderedItems[i], again.OrderedItems[i])
+		}
+	}
+}
+
+func TestScenarioErrors(t *testing.T) {
+	tests := []struct {
+		name, from, to, err string
+	}{
+		{"unknown field", "first_name: Ana,", "firstname: Ana,", "not found in type"},
+		{"duplicate ref", "{ref: tom,", "{ref: ana,", `employees[1]: missing or duplicate ref "ana"`},
+		{"unknown business", "{ref: pie, business: diner", "{ref: pie, business: cafe", `menu_items[0]: unknown business "cafe"`},
+		{"bad time zone", "time_zone: America/Chicago", "time_zone: Mars/Olympus", `businesses[0]: bad time_zone "Mars/Olympus"`},
+		{"unknown check employee", "business: diner, employee: ana,", "business: diner, employee: eve,", `checks[0]: unknown employee "eve"`},
+		{"check employee of another business", "business: diner, employee: ana,", "business: diner, employee: kofi,",
+			`checks[0]: employee "kofi" is not of business "diner"`},
+		{"item of another business", "{check: open, item: beer}", "{check: open, item: pie}", `ordered_items[2]: unknown menu item "pie"`},
+		{"ordered item employee of another business", "employee: tom, price", "employee: kofi, price",
+			`ordered_items[1]: employee "kofi" is not of the business of check "lunch"`},
+		{"unknown ordered item employee", "employee: tom, price", "employee: eve, price", `ordered_items[1]: unknown employee "eve"`},
+		{"unknown check", "{check: open,", "{check: dinner,", `ordered_items[2]: unknown check "dinner"`},
+		{"bad amount", `price: "3.50"`, `price: "3,50"`, `ordered_items[1]: price: bad amount "3,50"`},
+		{"no pay rate", `clock_in: 2018-11-13T01:00:00Z, pay_rate: "15.00"`, "clock_in: 2018-11-13T01:00:00Z",
+			`labor_entries[1]: no pay_rate, nor one of employee "kofi"`},
+		{"missing clock in", "clock_in: 2018-11-12T14:00:00Z, ", "", "labor_entries[0]: missing or bad clock_in"},
+		{"clock out before clock in", "clock_out: 2018-11-12T22:00:00Z", "clock_out: 2018-11-12T12:00:00Z",
+			"labor_entries[0]: clock_out before clock_in"},
+		{"missing check created_at", "created_at: 2018-11-13T02:00:00Z", "", "checks[1]: missing or bad created_at"},
+	}
+	env := testEnvelope(t)
+	for _, tt := range tests {
+		if !strings.Contains(testScenario, tt.from) {
+			t.Fatalf("%s: %q not in the scenario", tt.name, tt.from)
+		}
+		sc, err := parseScenario([]byte(strings.Replace(testScenario, tt.from, tt.to, 1)))
+		if err == nil {
+			_, err = sc.snapshot(env)
+		}
+		if _, ok := err.(scenarioError); !ok || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want a scenarioError with %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestBuiltInScenarios(t *testing.T) {
+	paths, err := filepath.Glob("scenarios/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("scenarios: %v, %v", paths, err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc, err := parseScenario(data)
+		if err == nil {
+			_, err = sc.snapshot(testEnvelope(t))
+		}
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,669 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"gopkg.in/inf.v0"
+	"strings"
+	"time"
+)
+
+// migrations are the versions of the SQL store schema, applied in order and
+// recorded in schema_migrations. Never edit a released one: append another.
+var migrations = []string{
+	// 1: the POS collections
+	`CREATE TABLE businesses (
+		id                     TEXT PRIMARY KEY,
+		name                   TEXT NOT NULL,
+		name_hash              TEXT NOT NULL,
+		hours                  INTEGER[] NOT NULL,
+		time_zone              TEXT NOT NULL,
+		encrypted_envelope_key TEXT NOT NULL,
+		envelope_key_id        TEXT NOT NULL,
+		service_key_id         TEXT NOT NULL,
+		initialization_vector  TEXT NOT NULL,
+		updated_at             TIMESTAMPTZ NOT NULL,
+		created_at             TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX businesses_name_hash ON businesses (name_hash);
+
+	CREATE TABLE checks (
+		id          TEXT PRIMARY KEY,
+		business_id TEXT NOT NULL REFERENCES businesses (id),
+		employee_id TEXT NOT NULL,
+		name        TEXT NOT NULL,
+		closed      BOOLEAN NOT NULL,
+		closed_at   TIMESTAMPTZ,
+		updated_at  TIMESTAMPTZ NOT NULL,
+		created_at  TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX checks_business_id ON checks (business_id, created_at, id);
+
+	CREATE TABLE employees (
+		id          TEXT PRIMARY KEY,
+		business_id TEXT NOT NULL REFERENCES businesses (id),
+		first_name  TEXT NOT NULL,
+		last_name   TEXT NOT NULL,
+		pay_rate    NUMERIC,
+		updated_at  TIMESTAMPTZ NOT NULL,
+		created_at  TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX employees_business_id ON employees (business_id, created_at, id);
+
+	CREATE TABLE labor_entries (
+		id          TEXT PRIMARY KEY,
+		business_id TEXT NOT NULL REFERENCES businesses (id),
+		employee_id TEXT NOT NULL,
+		name        TEXT NOT NULL,
+		clock_in    TIMESTAMPTZ NOT NULL,
+		clock_out   TIMESTAMPTZ,
+		pay_rate    NUMERIC,
+		updated_at  TIMESTAMPTZ NOT NULL,
+		created_at  TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX labor_entries_business_id ON labor_entries (business_id, created_at, id);
+
+	CREATE TABLE menu_items (
+		id          TEXT PRIMARY KEY,
+		business_id TEXT NOT NULL REFERENCES businesses (id),
+		name        TEXT NOT NULL,
+		cost        NUMERIC,
+		price       NUMERIC,
+		updated_at  TIMESTAMPTZ NOT NULL,
+		created_at  TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX menu_items_business_id ON menu_items (business_id, created_at, id);
+
+	CREATE TABLE ordered_items (
+		id          TEXT PRIMARY KEY,
+		business_id TEXT NOT NULL REFERENCES businesses (id),
+		employee_id TEXT NOT NULL,
+		check_id    TEXT NOT NULL,
+		item_id     TEXT NOT NULL,
+		name        TEXT NOT NULL,
+		cost        NUMERIC,
+		price       NUMERIC,
+		voided      BOOLEAN NOT NULL,
+		updated_at  TIMESTAMPTZ NOT NULL,
+		created_at  TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX ordered_items_business_id ON ordered_items (business_id, created_at, id);`,
+}
+
+// sqlStore is the Storage of a PostgreSQL database, through lib/pq; the
+// schema and queries use PostgreSQL types and syntax. Lists are ordered by
+// created date, then ID, and filtered and paged by the database.
+type sqlStore struct {
+	db *sql.DB
+	q  queryer // db, or the transaction of a snapshot or restore
+}
+
+// queryer is a *sql.DB or *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// newSQLStore migrates db to the latest schema version and returns its store.
+func newSQLStore(db *sql.DB) (sqlStore, error) {
+	s := sqlStore{db, db}
+	if err := s.migrate(); err != nil {
+		return sqlStore{}, err
+	}
+	return s, nil
+}
+
+// migrate applies the migrations the database has not had yet, each in its
+// own transaction.
+func (s sqlStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`); err != nil {
+		return err
+	}
+	var version int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	for v := version + 1; v <= len(migrations); v++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[v-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %v", v, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`, v, time.Now()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %v", v, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %v", v, err)
+		}
+	}
+	return nil
+}
+
+// insert runs an INSERT ... ON CONFLICT (id) DO NOTHING, which inserts no
+// row if the ID is taken.
+func (s sqlStore) insert(query string, args ...interface{}) error {
+	result, err := s.q.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return errExists
+	}
+	return nil
+}
+
+// change runs an UPDATE or DELETE of a record by ID.
+func (s sqlStore) change(query string, args ...interface{}) error {
+	result, err := s.q.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// listQuery returns the SELECT of columns from table, and its arguments, for
+// the page of filter: the business and date filters, LIMIT and OFFSET are
+// left to the database. A zero limit selects every row after the offset. The
+// clocked range only applies to labor_entries, the one table with shifts.
+func listQuery(columns, table string, filter BusinessesRequest) (string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.BusinessID != "" {
+		where = append(where, "business_id = "+arg(filter.BusinessID))
+	}
+	if !filter.UpdatedStart.IsZero() {
+		where = append(where, "updated_at >= "+arg(filter.UpdatedStart))
+	}
+	if !filter.UpdatedEnd.IsZero() {
+		where = append(where, "updated_at < "+arg(filter.UpdatedEnd))
+	}
+	if !filter.CreatedStart.IsZero() {
+		where = append(where, "created_at >= "+arg(filter.CreatedStart))
+	}
+	if !filter.CreatedEnd.IsZero() {
+		where = append(where, "created_at < "+arg(filter.CreatedEnd))
+	}
+	if table == "labor_entries" && !filter.ClockedStart.IsZero() {
+		where = append(where, "(clock_out IS NULL OR clock_out > "+arg(filter.ClockedStart)+")")
+	}
+	if table == "labor_entries" && !filter.ClockedEnd.IsZero() {
+		where = append(where, "clock_in < "+arg(filter.ClockedEnd))
+	}
+	query := `SELECT ` + columns + ` FROM ` + table
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY created_at, id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ` + arg(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query += ` OFFSET ` + arg(filter.Offset)
+	}
+	return query, args
+}
+
+// scanner is a *sql.Row or *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const businessColumns = `id, name, name_hash, hours, time_zone, encrypted_envelope_key, envelope_key_id, service_key_id, initialization_vector, updated_at, created_at`
+
+func scanBusiness(row scanner) (EncryptedBusiness, error) {
+	var (
+		b     EncryptedBusiness
+		hours pq.Int64Array
+	)
+	err := row.Scan(&b.ID, &b.Name, &b.NameHash, &hours, &b.TimeZone, &b.EncryptedEnvelopeKey,
+		&b.EnvelopeKeyID, &b.ServiceKeyID, &b.InitializationVector, &b.UpdatedAt, &b.CreatedAt)
+	b.Hours = make([]int, len(hours))
+	for i, h := range hours {
+		b.Hours[i] = int(h)
+	}
+	return b, err
+}
+
+func (s sqlStore) CreateBusiness(b EncryptedBusiness) (string, error) {
+	return b.ID, s.insert(`INSERT INTO businesses (`+businessColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) ON CONFLICT (id) DO NOTHING`,
+		b.ID, b.Name, b.NameHash, intArray(b.Hours), b.TimeZone, b.EncryptedEnvelopeKey,
+		b.EnvelopeKeyID, b.ServiceKeyID, b.InitializationVector, b.UpdatedAt, b.CreatedAt)
+}
+
+func (s sqlStore) GetBusinessByID(id string) (EncryptedBusiness, error) {
+	b, err := scanBusiness(s.q.QueryRow(`SELECT `+businessColumns+` FROM businesses WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return EncryptedBusiness{}, nil
+	}
+	return b, err
+}
+
+func (s sqlStore) GetBusinessByNameHash(nameHash string) (EncryptedBusiness, error) {
+	b, err := scanBusiness(s.q.QueryRow(`SELECT `+businessColumns+` FROM businesses
+		WHERE name_hash = $1 ORDER BY created_at, id LIMIT 1`, nameHash))
+	if err == sql.ErrNoRows {
+		return EncryptedBusiness{}, nil
+	}
+	return b, err
+}
+
+func (s sqlStore) GetAllBusinesses() ([]EncryptedBusiness, error) {
+	rows, err := s.q.Query(`SELECT ` + businessColumns + ` FROM businesses ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := make([]EncryptedBusiness, 0)
+	for rows.Next() {
+		b, err := scanBusiness(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, b)
+	}
+	return entities, rows.Err()
+}
+
+func (s sqlStore) UpdateBusinessByID(b EncryptedBusiness, id string) (string, error) {
+	return b.ID, s.change(`UPDATE businesses SET name = $1, name_hash = $2, hours = $3, time_zone = $4,
+		encrypted_envelope_key = $5, envelope_key_id = $6, service_key_id = $7, initialization_vector = $8,
+		updated_at = $9, created_at = $10 WHERE id = $11`,
+		b.Name, b.NameHash, intArray(b.Hours), b.TimeZone, b.EncryptedEnvelopeKey,
+		b.EnvelopeKeyID, b.ServiceKeyID, b.InitializationVector, b.UpdatedAt, b.CreatedAt, id)
+}
+
+const checkColumns = `id, business_id, employee_id, name, closed, closed_at, updated_at, created_at`
+
+func scanCheck(row scanner) (Check, error) {
+	var (
+		c        Check
+		closedAt sql.NullTime
+	)
+	err := row.Scan(&c.ID, &c.BusinessID, &c.EmployeeID, &c.Name, &c.Closed, &closedAt, &c.UpdatedAt, &c.CreatedAt)
+	c.ClosedAt = closedAt.Time
+	return c, err
+}
+
+func (s sqlStore) queryChecks(query string, args ...interface{}) ([]Check, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := make([]Check, 0)
+	for rows.Next() {
+		c, err := scanCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, c)
+	}
+	return entities, rows.Err()
+}
+
+func (s sqlStore) CreateCheck(c Check) (string, error) {
+	return c.ID, s.insert(`INSERT INTO checks (`+checkColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (id) DO NOTHING`,
+		c.ID, c.BusinessID, c.EmployeeID, c.Name, c.Closed, timestamp(c.ClosedAt), c.UpdatedAt, c.CreatedAt)
+}
+
+func (s sqlStore) GetCheckByID(id string) (Check, error) {
+	c, err := scanCheck(s.q.QueryRow(`SELECT `+checkColumns+` FROM checks WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return Check{}, nil
+	}
+	return c, err
+}
+
+func (s sqlStore) GetCheckByName(name string) (Check, error) {
+	c, err := scanCheck(s.q.QueryRow(`SELECT `+checkColumns+` FROM checks
+		WHERE name = $1 ORDER BY created_at, id LIMIT 1`, name))
+	if err == sql.ErrNoRows {
+		return Check{}, nil
+	}
+	return c, err
+}
+
+func (s sqlStore) GetChecks(filter BusinessesRequest) ([]Check, error) {
+	query, args := listQuery(checkColumns, "checks", filter)
+	return s.queryChecks(query, args...)
+}
+
+func (s sqlStore) GetAllChecks() ([]Check, error) {
+	return s.queryChecks(`SELECT ` + checkColumns + ` FROM checks ORDER BY created_at, id`)
+}
+
+func (s sqlStore) UpdateCheckByID(c Check, id string) (string, error) {
+	return c.ID, s.change(`UPDATE checks SET business_id = $1, employee_id = $2, name = $3, closed = $4,
+		closed_at = $5, updated_at = $6, created_at = $7 WHERE id = $8`,
+		c.BusinessID, c.EmployeeID, c.Name, c.Closed, timestamp(c.ClosedAt), c.UpdatedAt, c.CreatedAt, id)
+}
+
+func (s sqlStore) DeleteCheckByID(id string) error {
+	return s.change(`DELETE FROM checks WHERE id = $1`, id)
+}
+
+const employeeColumns = `id, business_id, first_name, last_name, pay_rate, updated_at, created_at`
+
+func scanEmployee(row scanner) (Employee, error) {
+	var e Employee
+	err := row.Scan(&e.ID, &e.BusinessID, &e.FirstName, &e.LastName, nullDecimal{&e.PayRate}, &e.UpdatedAt, &e.CreatedAt)
+	return e, err
+}
+
+func (s sqlStore) queryEmployees(query string, args ...interface{}) ([]Employee, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := make([]Employee, 0)
+	for rows.Next() {
+		e, err := scanEmployee(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+func (s sqlStore) CreateEmployee(e Employee) (string, error) {
+	return e.ID, s.insert(`INSERT INTO employees (`+employeeColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING`,
+		e.ID, e.BusinessID, e.FirstName, e.LastName, decimal(e.PayRate), e.UpdatedAt, e.CreatedAt)
+}
+
+func (s sqlStore) GetEmployeeByID(employeeID string) (Employee, error) {
+	e, err := scanEmployee(s.q.QueryRow(`SELECT `+employeeColumns+` FROM employees WHERE id = $1`, employeeID))
+	if err == sql.ErrNoRows {
+		return Employee{}, nil
+	}
+	return e, err
+}
+
+// GetEmployeeByName looks an employee up by first name followed by last name.
+func (s sqlStore) GetEmployeeByName(name string) (Employee, error) {
+	e, err := scanEmployee(s.q.QueryRow(`SELECT `+employeeColumns+` FROM employees
+		WHERE first_name || last_name = $1 ORDER BY created_at, id LIMIT 1`, name))
+	if err == sql.ErrNoRows {
+		return Employee{}, nil
+	}
+	return e, err
+}
+
+func (s sqlStore) GetEmployees(filter BusinessesRequest) ([]Employee, error) {
+	query, args := listQuery(employeeColumns, "employees", filter)
+	return s.queryEmployees(query, args...)
+}
+
+func (s sqlStore) GetAllEmployees() ([]Employee, error) {
+	return s.queryEmployees(`SELECT ` + employeeColumns + ` FROM employees ORDER BY created_at, id`)
+}
+
+func (s sqlStore) UpdateEmployeeByID(e Employee, id string) (string, error) {
+	return e.ID, s.change(`UPDATE employees SET business_id = $1, first_name = $2, last_name = $3,
+		pay_rate = $4, updated_at = $5, created_at = $6 WHERE id = $7`,
+		e.BusinessID, e.FirstName, e.LastName, decimal(e.PayRate), e.UpdatedAt, e.CreatedAt, id)
+}
+
+func (s sqlStore) DeleteEmployeeByID(id string) error {
+	return s.change(`DELETE FROM employees WHERE id = $1`, id)
+}
+
+const laborEntryColumns = `id, business_id, employee_id, name, clock_in, clock_out, pay_rate, updated_at, created_at`
+
+func scanLaborEntry(row scanner) (LaborEntry, error) {
+	var (
+		l        LaborEntry
+		clockOut sql.NullTime
+	)
+	err := row.Scan(&l.ID, &l.BusinessID, &l.EmployeeID, &l.Name, &l.ClockIn, &clockOut,
+		nullDecimal{&l.PayRate}, &l.UpdatedAt, &l.CreatedAt)
+	l.ClockOut = clockOut.Time
+	return l, err
+}
+
+func (s sqlStore) queryLaborEntries(query string, args ...interface{}) ([]LaborEntry, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := make([]LaborEntry, 0)
+	for rows.Next() {
+		l, err := scanLaborEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, l)
+	}
+	return entities, rows.Err()
+}
+
+func (s sqlStore) CreateLaborEntry(l LaborEntry) (string, error) {
+	return l.ID, s.insert(`INSERT INTO labor_entries (`+laborEntryColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (id) DO NOTHING`,
+		l.ID, l.BusinessID, l.EmployeeID, l.Name, l.ClockIn, timestamp(l.ClockOut),
+		decimal(l.PayRate), l.UpdatedAt, l.CreatedAt)
+}
+
+func (s sqlStore) GetLaborEntries(filter BusinessesRequest) ([]LaborEntry, error) {
+	query, args := listQuery(laborEntryColumns, "labor_entries", filter)
+	return s.queryLaborEntries(query, args...)
+}
+
+func (s sqlStore) GetAllLaborEntries() ([]LaborEntry, error) {
+	return s.queryLaborEntries(`SELECT ` + laborEntryColumns + ` FROM labor_entries ORDER BY created_at, id`)
+}
+
+const menuItemColumns = `id, business_id, name, cost, price, updated_at, created_at`
+
+func scanMenuItem(row scanner) (MenuItem, error) {
+	var m MenuItem
+	err := row.Scan(&m.ID, &m.BusinessID, &m.Name, nullDecimal{&m.Cost}, nullDecimal{&m.Price}, &m.UpdatedAt, &m.CreatedAt)
+	return m, err
+}
+
+func (s sqlStore) queryMenuItems(query string, args ...interface{}) ([]MenuItem, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := make([]MenuItem, 0)
+	for rows.Next() {
+		m, err := scanMenuItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, m)
+	}
+	return entities, rows.Err()
+}
+
+func (s sqlStore) CreateMenuItem(m MenuItem) (string, error) {
+	return m.ID, s.insert(`INSERT INTO menu_items (`+menuItemColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING`,
+		m.ID, m.BusinessID, m.Name, decimal(m.Cost), decimal(m.Price), m.UpdatedAt, m.CreatedAt)
+}
+
+func (s sqlStore) GetMenuItemByID(id string) (MenuItem, error) {
+	m, err := scanMenuItem(s.q.QueryRow(`SELECT `+menuItemColumns+` FROM menu_items WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return MenuItem{}, nil
+	}
+	return m, err
+}
+
+func (s sqlStore) GetMenuItemByName(name string) (MenuItem, error) {
+	m, err := scanMenuItem(s.q.QueryRow(`SELECT `+menuItemColumns+` FROM menu_items
+		WHERE name = $1 ORDER BY created_at, id LIMIT 1`, name))
+	if err == sql.ErrNoRows {
+		return MenuItem{}, nil
+	}
+	return m, err
+}
+
+func (s sqlStore) GetMenuItems(filter BusinessesRequest) ([]MenuItem, error) {
+	query, args := listQuery(menuItemColumns, "menu_items", filter)
+	return s.queryMenuItems(query, args...)
+}
+
+func (s sqlStore) GetAllMenuItems() ([]MenuItem, error) {
+	return s.queryMenuItems(`SELECT ` + menuItemColumns + ` FROM menu_items ORDER BY created_at, id`)
+}
+
+func (s sqlStore) UpdateMenuItemByID(m MenuItem, id string) (string, error) {
+	return m.ID, s.change(`UPDATE menu_items SET business_id = $1, name = $2, cost = $3, price = $4,
+		updated_at = $5, created_at = $6 WHERE id = $7`,
+		m.BusinessID, m.Name, decimal(m.Cost), decimal(m.Price), m.UpdatedAt, m.CreatedAt, id)
+}
+
+func (s sqlStore) DeleteMenuItemByID(id string) error {
+	return s.change(`DELETE FROM menu_items WHERE id = $1`, id)
+}
+
+const orderedItemColumns = `id, business_id, employee_id, check_id, item_id, name, cost, price, voided, updated_at, created_at`
+
+func scanOrderedItem(row scanner) (OrderedItem, error) {
+	var o OrderedItem
+	err := row.Scan(&o.ID, &o.BusinessID, &o.EmployeeID, &o.CheckID, &o.ItemID, &o.Name,
+		nullDecimal{&o.Cost}, nullDecimal{&o.Price}, &o.Voided, &o.UpdatedAt, &o.CreatedAt)
+	return o, err
+}
+
+func (s sqlStore) queryOrderedItems(query string, args ...interface{}) ([]OrderedItem, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := make([]OrderedItem, 0)
+	for rows.Next() {
+		o, err := scanOrderedItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, o)
+	}
+	return entities, rows.Err()
+}
+
+func (s sqlStore) CreateOrderedItem(o OrderedItem) (string, error) {
+	return o.ID, s.insert(`INSERT INTO ordered_items (`+orderedItemColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) ON CONFLICT (id) DO NOTHING`,
+		o.ID, o.BusinessID, o.EmployeeID, o.CheckID, o.ItemID, o.Name,
+		decimal(o.Cost), decimal(o.Price), o.Voided, o.UpdatedAt, o.CreatedAt)
+}
+
+func (s sqlStore) GetOrderedItems(filter BusinessesRequest) ([]OrderedItem, error) {
+	query, args := listQuery(orderedItemColumns, "ordered_items", filter)
+	return s.queryOrderedItems(query, args...)
+}
+
+func (s sqlStore) GetAllOrderedItems() ([]OrderedItem, error) {
+	return s.queryOrderedItems(`SELECT ` + orderedItemColumns + ` FROM ordered_items ORDER BY created_at, id`)
+}
+
+// Snapshot reads the tables in one repeatable read transaction.
+func (s sqlStore) Snapshot() (Snapshot, error) {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer tx.Rollback()
+	return dump(sqlStore{s.db, tx})
+}
+
+// Restore empties the tables and loads snapshot in one transaction.
+func (s sqlStore) Restore(snapshot Snapshot) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"ordered_items", "menu_items", "labor_entries", "employees", "checks", "businesses"} {
+		if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+			return err
+		}
+	}
+	if err := load(sqlStore{s.db, tx}, snapshot); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// intArray is the INTEGER[] column value of h, e.g. business hours.
+func intArray(h []int) interface{} {
+	a := make(pq.Int64Array, len(h))
+	for i, hour := range h {
+		a[i] = int64(hour)
+	}
+	return a
+}
+
+// timestamp is the column value of t, NULL for the zero time, e.g. of a check
+// still open.
+func timestamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// decimal is the NUMERIC column value of d, NULL for nil.
+func decimal(d *inf.Dec) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.String()
+}
+
+// nullDecimal scans a NUMERIC column into a *inf.Dec, nil for NULL.
+type nullDecimal struct {
+	dec **inf.Dec
+}
+
+func (n nullDecimal) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*n.dec = nil
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64, float64:
+		s = fmt.Sprint(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a decimal", src)
+	}
+	d, ok := new(inf.Dec).SetString(s)
+	if !ok {
+		return fmt.Errorf("cannot scan %q into a decimal", s)
+	}
+	*n.dec = d
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// TestSQLStorage runs against the PostgreSQL database of MOCKPOS_TEST_DSN,
+// e.g. postgres://localhost/mockpos_test?sslmode=disable, whose mock-pos
+// tables it empties. Without it the test is skipped.
+func TestSQLStorage(t *testing.T) {
+	dsn := os.Getenv("MOCKPOS_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MOCKPOS_TEST_DSN not set")
+	}
+	s, err := openStorage(storePostgres, dsn, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.(sqlStore).db.Close()
+	if err := s.Restore(Snapshot{}); err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+}
+
+func TestListQuery(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2018, 11, day, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		filter BusinessesRequest
+		query  string
+		args   int
+	}{
+		{BusinessesRequest{}, `SELECT id FROM checks ORDER BY created_at, id`, 0},
+		{BusinessesRequest{BusinessID: "b1", Limit: 10, Offset: 20},
+			`SELECT id FROM checks WHERE business_id = $1 ORDER BY created_at, id LIMIT $2 OFFSET $3`, 3},
+		{BusinessesRequest{UpdatedStart: at(1), UpdatedEnd: at(2), CreatedStart: at(3), CreatedEnd: at(4), Limit: 5},
+			`SELECT id FROM checks WHERE updated_at >= $1 AND updated_at < $2 AND created_at >= $3 AND created_at < $4 ORDER BY created_at, id LIMIT $5`, 5},
+	}
+	for _, tt := range tests {
+		query, args := listQuery("id", "checks", tt.filter)
+		if query != tt.query || len(args) != tt.args {
+			t.Errorf("listQuery(%+v) = %q with %d args, want %q with %d", tt.filter, query, len(args), tt.query, tt.args)
+		}
+	}
+
+	shifts := BusinessesRequest{BusinessID: "b1", ClockedStart: at(1), ClockedEnd: at(2)}
+	want := `SELECT id FROM labor_entries WHERE business_id = $1 AND (clock_out IS NULL OR clock_out > $2) AND clock_in < $3 ORDER BY created_at, id`
+	if query, args := listQuery("id", "labor_entries", shifts); query != want || len(args) != 3 {
+		t.Errorf("listQuery(%+v) = %q with %d args, want %q with 3", shifts, query, len(args), want)
+	}
+}
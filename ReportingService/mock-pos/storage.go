@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Storage keeps the POS records. Lists come in a stable order, so pages of
+// them are too, and those of a BusinessesRequest are the page of records
+// passing its business and date filters. The order is that of the store:
+// creation order in memory, ID order in a file and created date, then ID, in
+// PostgreSQL, so a dataset pages differently from one store to another. Creating a record whose ID is taken fails with errExists,
+// updating or deleting a missing one with errNotFound, and looking a missing
+// one up returns the zero record.
+type Storage interface {
+	CreateBusiness(EncryptedBusiness) (string, error)
+	GetBusinessByID(string) (EncryptedBusiness, error)
+	GetBusinessByNameHash(string) (EncryptedBusiness, error)
+	GetAllBusinesses() ([]EncryptedBusiness, error)
+	UpdateBusinessByID(EncryptedBusiness, string) (string, error)
+
+	CreateCheck(Check) (string, error)
+	GetCheckByID(string) (Check, error)
+	GetCheckByName(string) (Check, error)
+	GetChecks(BusinessesRequest) ([]Check, error)
+	GetAllChecks() ([]Check, error)
+	UpdateCheckByID(Check, string) (string, error)
+	DeleteCheckByID(string) error
+
+	CreateEmployee(Employee) (string, error)
+	GetEmployeeByID(string) (Employee, error)
+	GetEmployeeByName(string) (Employee, error)
+	GetEmployees(BusinessesRequest) ([]Employee, error)
+	GetAllEmployees() ([]Employee, error)
+	UpdateEmployeeByID(Employee, string) (string, error)
+	DeleteEmployeeByID(string) error
+
+	CreateLaborEntry(LaborEntry) (string, error)
+	GetLaborEntries(BusinessesRequest) ([]LaborEntry, error)
+	GetAllLaborEntries() ([]LaborEntry, error)
+
+	CreateMenuItem(MenuItem) (string, error)
+	GetMenuItemByID(string) (MenuItem, error)
+	GetMenuItemByName(string) (MenuItem, error)
+	GetMenuItems(BusinessesRequest) ([]MenuItem, error)
+	GetAllMenuItems() ([]MenuItem, error)
+	UpdateMenuItemByID(MenuItem, string) (string, error)
+	DeleteMenuItemByID(string) error
+
+	CreateOrderedItem(OrderedItem) (string, error)
+	GetOrderedItems(BusinessesRequest) ([]OrderedItem, error)
+	GetAllOrderedItems() ([]OrderedItem, error)
+
+	// Snapshot reads every record at one point in time, and Restore replaces
+	// them all with those of a snapshot, or leaves them as they were if it
+	// fails.
+	Snapshot() (Snapshot, error)
+	Restore(Snapshot) error
+}
+
+// pager picks the page of a BusinessesRequest out of the records passing its
+// filters, as a list gets to them: it skips the first Offset, then takes
+// Limit of them, or all the rest for a zero Limit.
+type pager struct {
+	skip, left int // left < 0 for no limit
+}
+
+func newPager(req BusinessesRequest) *pager {
+	left := req.Limit
+	if left <= 0 {
+		left = -1
+	}
+	return &pager{skip: req.Offset, left: left}
+}
+
+// take reports whether the next record passing the filters is on the page.
+func (p *pager) take() bool {
+	if p.skip > 0 {
+		p.skip--
+		return false
+	}
+	if p.left == 0 {
+		return false
+	}
+	if p.left > 0 {
+		p.left--
+	}
+	return true
+}
+
+// full reports whether the page has all its records, so a list can stop.
+func (p *pager) full() bool {
+	return p.left == 0
+}
+
+// Storages selectable with the -store flag
+const (
+	storeMemory   = "memory"   // lost on exit
+	storePostgres = "postgres" // the -db PostgreSQL database, migrated on start
+	storeFile     = "file"     // the -db-file bbolt file, kept across restarts
+)
+
+// openStorage returns the Storage of kind, connecting to dsn for a database
+// or opening path for a file.
+func openStorage(kind, dsn, path string) (Storage, error) {
+	switch kind {
+	case storeMemory:
+		return newStore(), nil
+	case storeFile:
+		return openBoltStore(path)
+	case storePostgres:
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, err
+		}
+		return newSQLStore(db)
+	}
+	return nil, fmt.Errorf("unknown store %q", kind)
+}
+
+// dump reads every record of s. It is a snapshot if s reads at one point in
+// time, e.g. in a transaction.
+func dump(s Storage) (Snapshot, error) {
+	var snapshot Snapshot
+	var err error
+	if snapshot.Businesses, err = s.GetAllBusinesses(); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Checks, err = s.GetAllChecks(); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Employees, err = s.GetAllEmployees(); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.LaborEntries, err = s.GetAllLaborEntries(); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.MenuItems, err = s.GetAllMenuItems(); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.OrderedItems, err = s.GetAllOrderedItems(); err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// load creates the records of snapshot in s, businesses first.
+func load(s Storage, snapshot Snapshot) error {
+	for _, b := range snapshot.Businesses {
+		if _, err := s.CreateBusiness(b); err != nil {
+			return err
+		}
+	}
+	for _, c := range snapshot.Checks {
+		if _, err := s.CreateCheck(c); err != nil {
+			return err
+		}
+	}
+	for _, e := range snapshot.Employees {
+		if _, err := s.CreateEmployee(e); err != nil {
+			return err
+		}
+	}
+	for _, l := range snapshot.LaborEntries {
+		if _, err := s.CreateLaborEntry(l); err != nil {
+			return err
+		}
+	}
+	for _, m := range snapshot.MenuItems {
+		if _, err := s.CreateMenuItem(m); err != nil {
+			return err
+		}
+	}
+	for _, o := range snapshot.OrderedItems {
+		if _, err := s.CreateOrderedItem(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
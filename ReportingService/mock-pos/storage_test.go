@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testStorage checks s, an empty store, against the Storage contract: the
+// errors of writes, and the filters, order and pages of lists.
+func testStorage(t *testing.T, s Storage) {
+	t0 := time.Date(2018, 11, 12, 0, 0, 0, 0, time.UTC)
+	hour := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Hour) }
+	for _, id := range []string{"b1", "b2"} {
+		if _, err := s.CreateBusiness(EncryptedBusiness{Business: Business{ID: id, Hours: []int{9, 17}, TimeZone: "UTC", CreatedAt: t0, UpdatedAt: t0}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// c1..c6 created an hour apart, alternating between b1 and b2, and the
+	// even ones updated 10 hours later
+	for i := 1; i <= 6; i++ {
+		c := Check{ID: fmt.Sprintf("c%d", i), BusinessID: fmt.Sprintf("b%d", 2-i%2), CreatedAt: hour(i), UpdatedAt: hour(i)}
+		if i%2 == 0 {
+			c.UpdatedAt = hour(i + 10)
+		}
+		if _, err := s.CreateCheck(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.CreateCheck(Check{ID: "c1", BusinessID: "b1", CreatedAt: t0, UpdatedAt: t0}); err != errExists {
+		t.Errorf("create taken ID: err = %v, want %v", err, errExists)
+	}
+	if _, err := s.UpdateCheckByID(Check{ID: "c9", BusinessID: "b1", CreatedAt: t0, UpdatedAt: t0}, "c9"); err != errNotFound {
+		t.Errorf("update missing: err = %v, want %v", err, errNotFound)
+	}
+	if err := s.DeleteCheckByID("c9"); err != errNotFound {
+		t.Errorf("delete missing: err = %v, want %v", err, errNotFound)
+	}
+	if c, err := s.GetCheckByID("c9"); err != nil || c.ID != "" {
+		t.Errorf("get missing = %+v, %v, want the zero check", c, err)
+	}
+
+	tests := []struct {
+		name   string
+		filter BusinessesRequest
+		want   string
+	}{
+		{"all", BusinessesRequest{}, "[c1 c2 c3 c4 c5 c6]"},
+		{"business", BusinessesRequest{BusinessID: "b2"}, "[c2 c4 c6]"},
+		{"unknown business", BusinessesRequest{BusinessID: "b3"}, "[]"},
+		{"created", BusinessesRequest{CreatedStart: hour(2), CreatedEnd: hour(5)}, "[c2 c3 c4]"},
+		{"created from", BusinessesRequest{CreatedStart: hour(5)}, "[c5 c6]"},
+		{"updated", BusinessesRequest{UpdatedStart: hour(7)}, "[c2 c4 c6]"},
+		{"updated until", BusinessesRequest{UpdatedEnd: hour(7)}, "[c1 c3 c5]"},
+		{"business and dates", BusinessesRequest{BusinessID: "b1", CreatedStart: hour(2), UpdatedEnd: hour(7)}, "[c3 c5]"},
+		{"limit", BusinessesRequest{Limit: 2}, "[c1 c2]"},
+		{"offset", BusinessesRequest{Offset: 4}, "[c5 c6]"},
+		{"page", BusinessesRequest{Limit: 2, Offset: 1}, "[c2 c3]"},
+		{"page of filtered", BusinessesRequest{BusinessID: "b2", Limit: 1, Offset: 1}, "[c4]"},
+		{"past the end", BusinessesRequest{Limit: 2, Offset: 6}, "[]"},
+		{"clocked, of labor entries only", BusinessesRequest{ClockedStart: hour(30), ClockedEnd: hour(31)}, "[c1 c2 c3 c4 c5 c6]"},
+	}
+	for _, tt := range tests {
+		checks, err := s.GetChecks(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if checks == nil {
+			t.Errorf("%s: nil list", tt.name)
+		}
+		ids := make([]string, 0, len(checks))
+		for _, c := range checks {
+			ids = append(ids, c.ID)
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("%s: ids = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// one record of each other collection per business
+	for i, business := range []string{"b1", "b2"} {
+		id := fmt.Sprintf("%d", i+1)
+		created := hour(i)
+		if _, err := s.CreateEmployee(Employee{ID: "e" + id, BusinessID: business, CreatedAt: created, UpdatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreateLaborEntry(LaborEntry{ID: "l" + id, BusinessID: business, ClockIn: created, CreatedAt: created, UpdatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreateMenuItem(MenuItem{ID: "m" + id, BusinessID: business, CreatedAt: created, UpdatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreateOrderedItem(OrderedItem{ID: "o" + id, BusinessID: business, CreatedAt: created, UpdatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// l1 and l2 above are still clocked in; l3 is a 30 hour shift, created at
+	// its clock in more than a day before l4
+	for _, l := range []LaborEntry{
+		{ID: "l3", BusinessID: "b1", ClockIn: hour(-30), ClockOut: hour(20), CreatedAt: hour(-30), UpdatedAt: hour(20)},
+		{ID: "l4", BusinessID: "b1", ClockIn: hour(2), ClockOut: hour(4), CreatedAt: hour(2), UpdatedAt: hour(4)},
+	} {
+		if _, err := s.CreateLaborEntry(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shifts := []struct {
+		name   string
+		filter BusinessesRequest
+		want   string
+	}{
+		{"on shift", BusinessesRequest{BusinessID: "b1", ClockedStart: hour(3), ClockedEnd: hour(4)}, "[l1 l3 l4]"},
+		{"after a clock out", BusinessesRequest{BusinessID: "b1", ClockedStart: hour(4), ClockedEnd: hour(6)}, "[l1 l3]"},
+		{"before a clock in", BusinessesRequest{BusinessID: "b1", ClockedEnd: hour(0)}, "[l3]"},
+		{"still clocked in", BusinessesRequest{ClockedStart: hour(21)}, "[l1 l2]"},
+	}
+	for _, tt := range shifts {
+		entries, err := s.GetLaborEntries(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		ids := make([]string, 0, len(entries))
+		for _, l := range entries {
+			ids = append(ids, l.ID)
+		}
+		sort.Strings(ids) // the order of the stores differs
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("%s: ids = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	b2 := BusinessesRequest{BusinessID: "b2"}
+	employees, err := s.GetEmployees(b2)
+	if err != nil || len(employees) != 1 || employees[0].ID != "e2" {
+		t.Errorf("employees of b2 = %+v, %v", employees, err)
+	}
+	laborEntries, err := s.GetLaborEntries(b2)
+	if err != nil || len(laborEntries) != 1 || laborEntries[0].ID != "l2" {
+		t.Errorf("labor entries of b2 = %+v, %v", laborEntries, err)
+	}
+	menuItems, err := s.GetMenuItems(b2)
+	if err != nil || len(menuItems) != 1 || menuItems[0].ID != "m2" {
+		t.Errorf("menu items of b2 = %+v, %v", menuItems, err)
+	}
+	orderedItems, err := s.GetOrderedItems(b2)
+	if err != nil || len(orderedItems) != 1 || orderedItems[0].ID != "o2" {
+		t.Errorf("ordered items of b2 = %+v, %v", orderedItems, err)
+	}
+
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteCheckByID("c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := s.GetCheckByID("c1"); err != nil || c.ID != "c1" {
+		t.Errorf("restored c1 = %+v, %v", c, err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, newStore())
+}
+
+func TestBoltStorage(t *testing.T) {
+	s, err := openBoltStore(filepath.Join(t.TempDir(), "mockpos.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.db.Close()
+	testStorage(t, s)
+}
+
+func TestBoltListsStopAtTheEndOfThePage(t *testing.T) {
+	s, err := openBoltStore(filepath.Join(t.TempDir(), "mockpos.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.db.Close()
+	t0 := time.Date(2018, 11, 12, 0, 0, 0, 0, time.UTC)
+	for _, id := range []string{"c1", "c2", "c3"} {
+		if _, err := s.CreateCheck(Check{ID: id, BusinessID: "b1", CreatedAt: t0, UpdatedAt: t0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// c4 cannot be decoded, so only a list reading past c3 fails
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltChecks.records).Put([]byte("c4"), []byte("{")); err != nil {
+			return err
+		}
+		return tx.Bucket(boltChecks.byBusiness).Put(indexKey("b1", "c4"), nil)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filter := range []BusinessesRequest{
+		{BusinessID: "b1", Limit: 2, Offset: 1},
+		{Limit: 3},
+		{BusinessID: "b1", CreatedStart: t0, Limit: 1, Offset: 2},
+	} {
+		if checks, err := s.GetChecks(filter); err != nil || len(checks) != filter.Limit {
+			t.Errorf("%+v: %d checks, %v", filter, len(checks), err)
+		}
+	}
+	if _, err := s.GetChecks(BusinessesRequest{BusinessID: "b1", Limit: 2, Offset: 2}); err == nil {
+		t.Error("listed c4")
+	}
+}
+
+func TestPager(t *testing.T) {
+	tests := []struct {
+		limit, offset int
+		want          string
+	}{
+		{0, 0, "[0 1 2 3 4]"},
+		{2, 0, "[0 1]"},
+		{2, 1, "[1 2]"},
+		{0, 3, "[3 4]"},
+		{10, 4, "[4]"},
+		{2, 5, "[]"},
+	}
+	for _, tt := range tests {
+		p := newPager(BusinessesRequest{Limit: tt.limit, Offset: tt.offset})
+		taken := make([]int, 0)
+		for i := 0; i < 5 && !p.full(); i++ {
+			if p.take() {
+				taken = append(taken, i)
+			}
+		}
+		if got := fmt.Sprint(taken); got != tt.want {
+			t.Errorf("limit %d, offset %d: took %s, want %s", tt.limit, tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+var errExists = errors.New("id already exists")
+
+// store is the in-memory Storage. Each collection is a table of records
+// by ID, indexed by business ID and kept in insertion order, so pages are
+// stable; a list copies the records of its page only, and stops there.
+// Requests are served concurrently: mu lets reads run together and writes one
+// at a time, and reads return copies of the records, never the tables
+// themselves.
+type store struct {
+	mu           sync.RWMutex
+	businesses   *table
+	checks       *table
+	employees    *table
+	laborEntries *table
+	menuItems    *table
+	orderedItems *table
+}
+
+func newStore() *store {
+	return &store{
+		businesses:   newTable(),
+		checks:       newTable(),
+		employees:    newTable(),
+		laborEntries: newTable(),
+		menuItems:    newTable(),
+		orderedItems: newTable(),
+	}
+}
+
+// table holds the records of a collection by ID, and their IDs in insertion
+// order, overall and per business. It is guarded by the mu of its store.
+type table struct {
+	rows       map[string]row
+	order      []string
+	byBusiness map[string][]string
+}
+
+type row struct {
+	businessID string
+	record     interface{}
+}
+
+func newTable() *table {
+	return &table{rows: make(map[string]row), byBusiness: make(map[string][]string)}
+}
+
+func (t *table) get(id string) (interface{}, bool) {
+	r, ok := t.rows[id]
+	return r.record, ok
+}
+
+func (t *table) insert(id, businessID string, record interface{}) error {
+	if _, ok := t.rows[id]; ok {
+		return errExists
+	}
+	t.rows[id] = row{businessID, record}
+	t.order = append(t.order, id)
+	t.byBusiness[businessID] = append(t.byBusiness[businessID], id)
+	return nil
+}
+
+// replace updates the record of id in place, moving it to the index of its
+// business if that changed.
+func (t *table) replace(id, businessID string, record interface{}) error {
+	old, ok := t.rows[id]
+	if !ok {
+		return errNotFound
+	}
+	if old.businessID != businessID {
+		t.unindex(old.businessID, id)
+		t.byBusiness[businessID] = append(t.byBusiness[businessID], id)
+	}
+	t.rows[id] = row{businessID, record}
+	return nil
+}
+
+func (t *table) remove(id string) error {
+	old, ok := t.rows[id]
+	if !ok {
+		return errNotFound
+	}
+	delete(t.rows, id)
+	t.order = without(t.order, id)
+	t.unindex(old.businessID, id)
+	return nil
+}
+
+func (t *table) unindex(businessID, id string) {
+	if ids := without(t.byBusiness[businessID], id); len(ids) > 0 {
+		t.byBusiness[businessID] = ids
+	} else {
+		delete(t.byBusiness, businessID)
+	}
+}
+
+// ids returns the IDs of businessID in order, or all of them if it is empty.
+func (t *table) ids(businessID string) []string {
+	if businessID == "" {
+		return t.order
+	}
+	return t.byBusiness[businessID]
+}
+
+// records returns the records of ids, in order.
+func (t *table) records(ids []string) []interface{} {
+	records := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		records = append(records, t.rows[id].record)
+	}
+	return records
+}
+
+// without returns ids less id, in a new slice so those handed out before stay
+// as they are.
+func without(ids []string, id string) []string {
+	rest := make([]string, 0, len(ids))
+	for _, other := range ids {
+		if other != id {
+			rest = append(rest, other)
+		}
+	}
+	return rest
+}
+
+func (s *store) CreateBusiness(b EncryptedBusiness) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return b.ID, s.businesses.insert(b.ID, b.ID, b)
+}
+
+func (s *store) GetBusinessByID(id string) (EncryptedBusiness, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if record, ok := s.businesses.get(id); ok {
+		return record.(EncryptedBusiness), nil
+	}
+	return EncryptedBusiness{}, nil
+}
+
+func (s *store) GetBusinessByNameHash(nameHash string) (EncryptedBusiness, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, record := range s.businesses.records(s.businesses.order) {
+		if entity := record.(EncryptedBusiness); entity.NameHash == nameHash {
+			return entity, nil
+		}
+	}
+	return EncryptedBusiness{}, nil
+}
+
+func (s *store) GetAllBusinesses() ([]EncryptedBusiness, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return businesses(s.businesses.records(s.businesses.order)), nil
+}
+
+func (s *store) UpdateBusinessByID(b EncryptedBusiness, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return b.ID, s.businesses.replace(id, id, b)
+}
+
+func businesses(records []interface{}) []EncryptedBusiness {
+	entities := make([]EncryptedBusiness, 0, len(records))
+	for _, record := range records {
+		entities = append(entities, record.(EncryptedBusiness))
+	}
+	return entities
+}
+
+func (s *store) CreateCheck(c Check) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return c.ID, s.checks.insert(c.ID, c.BusinessID, c)
+}
+
+func (s *store) GetCheckByID(id string) (Check, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if record, ok := s.checks.get(id); ok {
+		return record.(Check), nil
+	}
+	return Check{}, nil
+}
+
+func (s *store) GetCheckByName(name string) (Check, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, record := range s.checks.records(s.checks.order) {
+		if entity := record.(Check); entity.Name == name {
+			return entity, nil
+		}
+	}
+	return Check{}, nil
+}
+
+func (s *store) GetChecks(filter BusinessesRequest) ([]Check, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entities := make([]Check, 0)
+	p := newPager(filter)
+	for _, id := range s.checks.ids(filter.BusinessID) {
+		if p.full() {
+			break
+		}
+		if entity := s.checks.rows[id].record.(Check); filter.matches(entity.BusinessID, entity.UpdatedAt, entity.CreatedAt) && p.take() {
+			entities = append(entities, entity)
+		}
+	}
+	return entities, nil
+}
+
+func (s *store) GetAllChecks() ([]Check, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return checks(s.checks.records(s.checks.order)), nil
+}
+
+func (s *store) UpdateCheckByID(c Check, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return c.ID, s.checks.replace(id, c.BusinessID, c)
+}
+
+func (s *store) DeleteCheckByID(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checks.remove(id)
+}
+
+func checks(records []interface{}) []Check {
+	entities := make([]Check, 0, len(records))
+	for _, record := range records {
+		entities = append(entities, record.(Check))
+	}
+	return entities
+}
+
+func (s *store) CreateEmployee(e Employee) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return e.ID, s.employees.insert(e.ID, e.BusinessID, e)
+}
+
+func (s *store) GetEmployeeByID(employeeID string) (Employee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if record, ok := s.employees.get(employeeID); ok {
+		return record.(Employee), nil
+	}
+	return Employee{}, nil
+}
+
+func (s *store) GetEmployeeByName(name string) (Employee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, record := range s.employees.records(s.employees.order) {
+		if entity := record.(Employee); entity.FirstName+entity.LastName == name {
+			return entity, nil
+		}
+	}
+	return Employee{}, nil
+}
+
+func (s *store) GetEmployees(filter BusinessesRequest) ([]Employee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entities := make([]Employee, 0)
+	p := newPager(filter)
+	for _, id := range s.employees.ids(filter.BusinessID) {
+		if p.full() {
+			break
+		}
+		if entity := s.employees.rows[id].record.(Employee); filter.matches(entity.BusinessID, entity.UpdatedAt, entity.CreatedAt) && p.take() {
+			entities = append(entities, entity)
+		}
+	}
+	return entities, nil
+}
+
+func (s *store) GetAllEmployees() ([]Employee, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return employees(s.employees.records(s.employees.order)), nil
+}
+
+func (s *store) UpdateEmployeeByID(e Employee, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return e.ID, s.employees.replace(id, e.BusinessID, e)
+}
+
+func (s *store) DeleteEmployeeByID(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.employees.remove(id)
+}
+
+func employees(records []interface{}) []Employee {
+	entities := make([]Employee, 0, len(records))
+	for _, record := range records {
+		entities = append(entities, record.(Employee))
+	}
+	return entities
+}
+
+func (s *store) CreateLaborEntry(l LaborEntry) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return l.ID, s.laborEntries.insert(l.ID, l.BusinessID, l)
+}
+
+func (s *store) GetLaborEntries(filter BusinessesRequest) ([]LaborEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entities := make([]LaborEntry, 0)
+	p := newPager(filter)
+	for _, id := range s.laborEntries.ids(filter.BusinessID) {
+		if p.full() {
+			break
+		}
+		if entity := s.laborEntries.rows[id].record.(LaborEntry); filter.matches(entity.BusinessID, entity.UpdatedAt, entity.CreatedAt) &&
+			filter.onShift(entity.ClockIn, entity.ClockOut) && p.take() {
+			entities = append(entities, entity)
+		}
+	}
+	return entities, nil
+}
+
+func (s *store) GetAllLaborEntries() ([]LaborEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return laborEntries(s.laborEntries.records(s.laborEntries.order)), nil
+}
+
+func laborEntries(records []interface{}) []LaborEntry {
+	entities := make([]LaborEntry, 0, len(records))
+	for _, record := range records {
+		entities = append(entities, record.(LaborEntry))
+	}
+	return entities
+}
+
+func (s *store) CreateMenuItem(m MenuItem) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return m.ID, s.menuItems.insert(m.ID, m.BusinessID, m)
+}
+
+func (s *store) GetMenuItemByID(id string) (MenuItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if record, ok := s.menuItems.get(id); ok {
+		return record.(MenuItem), nil
+	}
+	return MenuItem{}, nil
+}
+
+func (s *store) GetMenuItemByName(name string) (MenuItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, record := range s.menuItems.records(s.menuItems.order) {
+		if entity := record.(MenuItem); entity.Name == name {
+			return entity, nil
+		}
+	}
+	return MenuItem{}, nil
+}
+
+func (s *store) GetMenuItems(filter BusinessesRequest) ([]MenuItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entities := make([]MenuItem, 0)
+	p := newPager(filter)
+	for _, id := range s.menuItems.ids(filter.BusinessID) {
+		if p.full() {
+			break
+		}
+		if entity := s.menuItems.rows[id].record.(MenuItem); filter.matches(entity.BusinessID, entity.UpdatedAt, entity.CreatedAt) && p.take() {
+			entities = append(entities, entity)
+		}
+	}
+	return entities, nil
+}
+
+func (s *store) GetAllMenuItems() ([]MenuItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return menuItems(s.menuItems.records(s.menuItems.order)), nil
+}
+
+func (s *store) UpdateMenuItemByID(m MenuItem, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return m.ID, s.menuItems.replace(id, m.BusinessID, m)
+}
+
+func (s *store) DeleteMenuItemByID(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.menuItems.remove(id)
+}
+
+func menuItems(records []interface{}) []MenuItem {
+	entities := make([]MenuItem, 0, len(records))
+	for _, record := range records {
+		entities = append(entities, record.(MenuItem))
+	}
+	return entities
+}
+
+func (s *store) CreateOrderedItem(o OrderedItem) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return o.ID, s.orderedItems.insert(o.ID, o.BusinessID, o)
+}
+
+func (s *store) GetOrderedItems(filter BusinessesRequest) ([]OrderedItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entities := make([]OrderedItem, 0)
+	p := newPager(filter)
+	for _, id := range s.orderedItems.ids(filter.BusinessID) {
+		if p.full() {
+			break
+		}
+		if entity := s.orderedItems.rows[id].record.(OrderedItem); filter.matches(entity.BusinessID, entity.UpdatedAt, entity.CreatedAt) && p.take() {
+			entities = append(entities, entity)
+		}
+	}
+	return entities, nil
+}
+
+func (s *store) GetAllOrderedItems() ([]OrderedItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return orderedItems(s.orderedItems.records(s.orderedItems.order)), nil
+}
+
+func orderedItems(records []interface{}) []OrderedItem {
+	entities := make([]OrderedItem, 0, len(records))
+	for _, record := range records {
+		entities = append(entities, record.(OrderedItem))
+	}
+	return entities
+}
+
+func (s *store) Snapshot() (Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Snapshot{
+		Businesses:   businesses(s.businesses.records(s.businesses.order)),
+		Checks:       checks(s.checks.records(s.checks.order)),
+		Employees:    employees(s.employees.records(s.employees.order)),
+		LaborEntries: laborEntries(s.laborEntries.records(s.laborEntries.order)),
+		MenuItems:    menuItems(s.menuItems.records(s.menuItems.order)),
+		OrderedItems: orderedItems(s.orderedItems.records(s.orderedItems.order)),
+	}, nil
+}
+
+// Restore loads snapshot into new tables, then swaps them in.
+func (s *store) Restore(snapshot Snapshot) error {
+	restored := newStore()
+	if err := load(restored, snapshot); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.businesses = restored.businesses
+	s.checks = restored.checks
+	s.employees = restored.employees
+	s.laborEntries = restored.laborEntries
+	s.menuItems = restored.menuItems
+	s.orderedItems = restored.orderedItems
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestStoreConcurrentAccess(t *testing.T) {
+	s := newStore()
+	businesses := []string{"b1", "b2"}
+	for _, id := range businesses {
+		if _, err := s.CreateBusiness(EncryptedBusiness{Business: Business{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := mockPOSService{store: s}
+
+	const workers, perWorker = 8, 60
+	var writers sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < perWorker; i++ {
+				id := fmt.Sprintf("c%d-%d", w, i)
+				from, to := businesses[i%2], businesses[(i+1)%2]
+				if _, err := s.CreateCheck(Check{ID: id, BusinessID: from}); err != nil {
+					t.Error(err)
+					return
+				}
+				// moves the check to the other business
+				if _, err := s.UpdateCheckByID(Check{ID: id, BusinessID: to, Name: "updated"}, id); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%3 == 0 {
+					if err := s.DeleteCheckByID(id); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := s.GetAllChecks(); err != nil {
+					t.Error(err)
+					return
+				}
+				for _, business := range businesses {
+					page, err := svc.Checks(BusinessesRequest{BusinessID: business, Limit: 10, Offset: 5})
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					for _, c := range page {
+						if c.BusinessID != business {
+							t.Errorf("check %s of %s listed under %s", c.ID, c.BusinessID, business)
+							return
+						}
+					}
+				}
+				if _, err := s.Snapshot(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	all, err := s.GetAllChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := (perWorker + 2) / 3
+	if want := workers * (perWorker - deleted); len(all) != want {
+		t.Errorf("got %d checks, want %d", len(all), want)
+	}
+	listed := 0
+	for _, business := range businesses {
+		checks, err := s.GetChecks(BusinessesRequest{BusinessID: business})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range checks {
+			if c.BusinessID != business || c.Name != "updated" {
+				t.Errorf("check %s = %+v, listed under %s", c.ID, c, business)
+			}
+		}
+		listed += len(checks)
+	}
+	if listed != len(all) {
+		t.Errorf("%d checks listed by business, %d in all", listed, len(all))
+	}
+}
+
+func TestStoreKeepsCreationOrder(t *testing.T) {
+	s := newStore()
+	for _, id := range []string{"c3", "c1", "c2"} {
+		if _, err := s.CreateCheck(Check{ID: id, BusinessID: "b1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.UpdateCheckByID(Check{ID: "c1", BusinessID: "b1", Name: "updated"}, "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteCheckByID("c3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateCheck(Check{ID: "c3", BusinessID: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.GetAllChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range all {
+		ids = append(ids, c.ID)
+	}
+	if fmt.Sprint(ids) != "[c1 c2 c3]" {
+		t.Errorf("ids = %v, want [c1 c2 c3]", ids)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	s := newStore()
+	if _, err := s.CreateCheck(Check{ID: "c1", BusinessID: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateCheck(Check{ID: "c1", BusinessID: "b1"}); err != errExists {
+		t.Errorf("create taken ID: err = %v, want %v", err, errExists)
+	}
+	if _, err := s.UpdateCheckByID(Check{ID: "c2"}, "c2"); err != errNotFound {
+		t.Errorf("update missing: err = %v, want %v", err, errNotFound)
+	}
+	if err := s.DeleteCheckByID("c2"); err != errNotFound {
+		t.Errorf("delete missing: err = %v, want %v", err, errNotFound)
+	}
+	if c, err := s.GetCheckByID("c2"); err != nil || c.ID != "" {
+		t.Errorf("get missing = %+v, %v, want the zero check", c, err)
+	}
+}
+
+func TestStoreListsStopAtTheEndOfThePage(t *testing.T) {
+	s := newStore()
+	for _, id := range []string{"c1", "c2", "c3"} {
+		if _, err := s.CreateCheck(Check{ID: id, BusinessID: "b1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// c4 is no check, so a list reading past c3 panics
+	if err := s.checks.insert("c4", "b1", "not a check"); err != nil {
+		t.Fatal(err)
+	}
+	for _, filter := range []BusinessesRequest{
+		{BusinessID: "b1", Limit: 2, Offset: 1},
+		{Limit: 3},
+	} {
+		if checks, err := s.GetChecks(filter); err != nil || len(checks) != filter.Limit {
+			t.Errorf("%+v: %d checks, %v", filter, len(checks), err)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/satori/go.uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	errNotFound        = errors.New("not found")
+	errUnknownBusiness = errors.New("unknown business_id")
+	errMissingID       = errors.New("missing id in path")
+)
+
+// Write operations let integration tests shape the POS data of a scenario.
+// Creates assign an ID unless one is given, updates keep the created date of
+// the record they replace, and both stamp the updated date.
+
+func (s mockPOSService) CreateCheck(c Check) (Check, error) {
+	if err := s.checkBusiness(c.BusinessID); err != nil {
+		return Check{}, err
+	}
+	c.ID = newID(c.ID)
+	c.UpdatedAt = time.Now()
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = c.UpdatedAt
+	}
+	if _, err := s.store.CreateCheck(c); err != nil {
+		return Check{}, err
+	}
+	return c, nil
+}
+
+func (s mockPOSService) UpdateCheck(c Check) (Check, error) {
+	old, err := s.store.GetCheckByID(c.ID)
+	if err != nil {
+		return Check{}, err
+	}
+	if old.ID == "" {
+		return Check{}, errNotFound
+	}
+	if err := s.checkBusiness(c.BusinessID); err != nil {
+		return Check{}, err
+	}
+	c.CreatedAt = old.CreatedAt
+	c.UpdatedAt = time.Now()
+	if _, err := s.store.UpdateCheckByID(c, c.ID); err != nil {
+		return Check{}, err
+	}
+	return c, nil
+}
+
+func (s mockPOSService) DeleteCheck(id string) error {
+	return s.store.DeleteCheckByID(id)
+}
+
+func (s mockPOSService) CreateEmployee(e Employee) (Employee, error) {
+	if err := s.checkBusiness(e.BusinessID); err != nil {
+		return Employee{}, err
+	}
+	e.ID = newID(e.ID)
+	e.UpdatedAt = time.Now()
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = e.UpdatedAt
+	}
+	if _, err := s.store.CreateEmployee(e); err != nil {
+		return Employee{}, err
+	}
+	return e, nil
+}
+
+func (s mockPOSService) UpdateEmployee(e Employee) (Employee, error) {
+	old, err := s.store.GetEmployeeByID(e.ID)
+	if err != nil {
+		return Employee{}, err
+	}
+	if old.ID == "" {
+		return Employee{}, errNotFound
+	}
+	if err := s.checkBusiness(e.BusinessID); err != nil {
+		return Employee{}, err
+	}
+	e.CreatedAt = old.CreatedAt
+	e.UpdatedAt = time.Now()
+	if _, err := s.store.UpdateEmployeeByID(e, e.ID); err != nil {
+		return Employee{}, err
+	}
+	return e, nil
+}
+
+func (s mockPOSService) DeleteEmployee(id string) error {
+	return s.store.DeleteEmployeeByID(id)
+}
+
+func (s mockPOSService) CreateMenuItem(m MenuItem) (MenuItem, error) {
+	if err := s.checkBusiness(m.BusinessID); err != nil {
+		return MenuItem{}, err
+	}
+	m.ID = newID(m.ID)
+	m.UpdatedAt = time.Now()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = m.UpdatedAt
+	}
+	if _, err := s.store.CreateMenuItem(m); err != nil {
+		return MenuItem{}, err
+	}
+	return m, nil
+}
+
+func (s mockPOSService) UpdateMenuItem(m MenuItem) (MenuItem, error) {
+	old, err := s.store.GetMenuItemByID(m.ID)
+	if err != nil {
+		return MenuItem{}, err
+	}
+	if old.ID == "" {
+		return MenuItem{}, errNotFound
+	}
+	if err := s.checkBusiness(m.BusinessID); err != nil {
+		return MenuItem{}, err
+	}
+	m.CreatedAt = old.CreatedAt
+	m.UpdatedAt = time.Now()
+	if _, err := s.store.UpdateMenuItemByID(m, m.ID); err != nil {
+		return MenuItem{}, err
+	}
+	return m, nil
+}
+
+func (s mockPOSService) DeleteMenuItem(id string) error {
+	return s.store.DeleteMenuItemByID(id)
+}
+
+// checkBusiness rejects records of a business the POS does not know.
+func (s mockPOSService) checkBusiness(businessID string) error {
+	if businessID == "" {
+		return ErrEmpty
+	}
+	bus, err := s.store.GetBusinessByID(businessID)
+	if err != nil {
+		return err
+	}
+	if bus.ID == "" {
+		return errUnknownBusiness
+	}
+	return nil
+}
+
+// newID returns id, or a new UUID when id is empty.
+func newID(id string) string {
+	if id != "" {
+		return id
+	}
+	return uuid.Must(uuid.NewV4()).String()
+}
+
+func makeCreateCheckEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return svc.CreateCheck(request.(Check))
+	}
+}
+
+func makeUpdateCheckEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return svc.UpdateCheck(request.(Check))
+	}
+}
+
+func makeDeleteCheckEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return struct{}{}, svc.DeleteCheck(request.(string))
+	}
+}
+
+func makeCreateEmployeeEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return svc.CreateEmployee(request.(Employee))
+	}
+}
+
+func makeUpdateEmployeeEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return svc.UpdateEmployee(request.(Employee))
+	}
+}
+
+func makeDeleteEmployeeEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return struct{}{}, svc.DeleteEmployee(request.(string))
+	}
+}
+
+func makeCreateMenuItemEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return svc.CreateMenuItem(request.(MenuItem))
+	}
+}
+
+func makeUpdateMenuItemEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return svc.UpdateMenuItem(request.(MenuItem))
+	}
+}
+
+func makeDeleteMenuItemEndpoint(svc MockPOSService) endpoint.Endpoint {
+	return func(_ context.Context, request interface{}) (interface{}, error) {
+		return struct{}{}, svc.DeleteMenuItem(request.(string))
+	}
+}
+
+// decodeCheckRequest reads a Check from the body. For PUT /checks/{id} the ID
+// is taken from the path.
+func decodeCheckRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request Check
+	if err := decodeBody(r, &request); err != nil {
+		return nil, err
+	}
+	if id := pathID(r); id != "" {
+		request.ID = id
+	}
+	return request, nil
+}
+
+// decodeEmployeeRequest reads an Employee from the body. For
+// PUT /employees/{id} the ID is taken from the path.
+func decodeEmployeeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request Employee
+	if err := decodeBody(r, &request); err != nil {
+		return nil, err
+	}
+	if id := pathID(r); id != "" {
+		request.ID = id
+	}
+	return request, nil
+}
+
+// decodeMenuItemRequest reads a MenuItem from the body. For
+// PUT /menu_items/{id} the ID is taken from the path.
+func decodeMenuItemRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request MenuItem
+	if err := decodeBody(r, &request); err != nil {
+		return nil, err
+	}
+	if id := pathID(r); id != "" {
+		request.ID = id
+	}
+	return request, nil
+}
+
+// bodyError is a request body which cannot be read or decoded, e.g. syntax
+// errors, values of the wrong type, bad decimals or an empty body, answered
+// with 400.
+type bodyError struct {
+	err error
+}
+
+func (e bodyError) Error() string {
+	return "bad request body: " + e.err.Error()
+}
+
+// decodeBody decodes the JSON body of r into v.
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return bodyError{err}
+	}
+	return nil
+}
+
+// decodeIDRequest reads the ID of DELETE /{collection}/{id}.
+func decodeIDRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id := pathID(r)
+	if id == "" {
+		return nil, errMissingID
+	}
+	return id, nil
+}
+
+// pathID returns the {id} of /{collection}/{id}, or "" for /{collection}.
+func pathID(r *http.Request) string {
+	parts := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// methods routes a request to the handler registered for its HTTP method.
+type methods map[string]http.Handler
+
+func (m methods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, ok := m[r.Method]
+	if !ok {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// encodeError maps the service errors onto HTTP status codes.
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	code := http.StatusInternalServerError
+	switch err {
+	case errNotFound:
+		code = http.StatusNotFound
+	case errExists:
+		code = http.StatusConflict
+	case ErrEmpty, errUnknownBusiness, errMissingID, errBadLimit, errBadOffset, errBadDate, errRecordWithoutID, errUnknownKey:
+		code = http.StatusBadRequest
+	default:
+		switch err.(type) {
+		case bodyError, scenarioError:
+			code = http.StatusBadRequest
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write([]byte(err.Error()))
+}
+
+func (mw loggingMiddleware) CreateCheck(c Check) (output Check, err error) {
+	defer mw.logWrite("createCheck", c.ID, &output.ID, &err, time.Now())
+	output, err = mw.next.CreateCheck(c)
+	return
+}
+
+func (mw loggingMiddleware) UpdateCheck(c Check) (output Check, err error) {
+	defer mw.logWrite("updateCheck", c.ID, &output.ID, &err, time.Now())
+	output, err = mw.next.UpdateCheck(c)
+	return
+}
+
+func (mw loggingMiddleware) DeleteCheck(id string) (err error) {
+	defer mw.logWrite("deleteCheck", id, &id, &err, time.Now())
+	err = mw.next.DeleteCheck(id)
+	return
+}
+
+func (mw loggingMiddleware) CreateEmployee(e Employee) (output Employee, err error) {
+	defer mw.logWrite("createEmployee", e.ID, &output.ID, &err, time.Now())
+	output, err = mw.next.CreateEmployee(e)
+	return
+}
+
+func (mw loggingMiddleware) UpdateEmployee(e Employee) (output Employee, err error) {
+	defer mw.logWrite("updateEmployee", e.ID, &output.ID, &err, time.Now())
+	output, err = mw.next.UpdateEmployee(e)
+	return
+}
+
+func (mw loggingMiddleware) DeleteEmployee(id string) (err error) {
+	defer mw.logWrite("deleteEmployee", id, &id, &err, time.Now())
+	err = mw.next.DeleteEmployee(id)
+	return
+}
+
+func (mw loggingMiddleware) CreateMenuItem(m MenuItem) (output MenuItem, err error) {
+	defer mw.logWrite("createMenuItem", m.ID, &output.ID, &err, time.Now())
+	output, err = mw.next.CreateMenuItem(m)
+	return
+}
+
+func (mw loggingMiddleware) UpdateMenuItem(m MenuItem) (output MenuItem, err error) {
+	defer mw.logWrite("updateMenuItem", m.ID, &output.ID, &err, time.Now())
+	output, err = mw.next.UpdateMenuItem(m)
+	return
+}
+
+func (mw loggingMiddleware) DeleteMenuItem(id string) (err error) {
+	defer mw.logWrite("deleteMenuItem", id, &id, &err, time.Now())
+	err = mw.next.DeleteMenuItem(id)
+	return
+}
+
+// logWrite logs a write operation once it returned; output and err point at
+// its named results.
+func (mw loggingMiddleware) logWrite(method, input string, output *string, err *error, begin time.Time) {
+	mw.logger.Log(
+		"callTime", time.Now().Format(TimeFormat),
+		"method", method,
+		"input", input,
+		"output", *output,
+		"err", *err,
+		"took", time.Since(begin),
+	)
+}
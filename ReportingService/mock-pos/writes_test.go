@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeErrorsAreBadRequests(t *testing.T) {
+	bodies := map[string]string{
+		"syntax":      `{"business_id":`,
+		"wrong type":  `{"business_id":1}`,
+		"bad decimal": `{"business_id":"b","price":"1.2.3"}`,
+		"empty":       ``,
+		"not object":  `[]`,
+	}
+	for name, body := range bodies {
+		r := httptest.NewRequest("POST", "/menu_items", strings.NewReader(body))
+		_, err := decodeMenuItemRequest(context.Background(), r)
+		if err == nil {
+			t.Errorf("%s: no error", name)
+			continue
+		}
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), err, w)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d (%v)", name, w.Code, http.StatusBadRequest, err)
+		}
+	}
+}
+
+func TestDecodeMenuItemRequestTakesIDFromPath(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/menu_items/m1", strings.NewReader(`{"id":"other","business_id":"b","price":"1.50"}`))
+	request, err := decodeMenuItemRequest(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := request.(MenuItem)
+	if item.ID != "m1" || item.BusinessID != "b" || item.Price.String() != "1.50" {
+		t.Errorf("item = %+v", item)
+	}
+}
@@ -10,6 +10,7 @@ type Business struct {
 	ID string `json:"id"`
 	Name string `json:"name"`
 	Hours []int `json:"hours"`
+	TimeZone string `json:"time_zone"` // IANA Time Zone database name, e.g. America/Los_Angeles
 	UpdatedAt time.Time `json:"updated_at"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/go-kit/kit/endpoint"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// posPageSize is the number of records requested per POS page, the maximum
+// limit the POS APIs accept.
+const posPageSize = 500
+
+var errTooManyPages = errors.New("POS: too many pages")
+
+// posClient fetches whole POS collections of a business by walking their
+// limit/offset pages through the (load balanced, retrying) POS endpoint.
+type posClient struct {
+	pos      endpoint.Endpoint
+	maxPages int     // safety cap on the pages fetched per collection
+	keys     keyring // unwraps the data keys of business names
+}
+
+// fetchAll requests the pages of the collection at path matching filter until
+// a short page shows it is exhausted. Each page is handed to appendPage, which
+// returns the number of records it held.
+func (c posClient) fetchAll(ctx context.Context, path string, filter BusinessesRequest, appendPage func(interface{}) int) error {
+	request := posRequest{Path: path, BusinessesRequest: filter}
+	request.Limit = posPageSize
+	for page := 0; ; page++ {
+		if page == c.maxPages {
+			return errTooManyPages
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		response, err := c.fetchPage(ctx, request)
+		if err != nil {
+			return err
+		}
+		n := appendPage(response)
+		if n < posPageSize {
+			return nil
+		}
+		request.Offset += n
+	}
+}
+
+// fetchPage requests one page, in a span covering the attempts of the load
+// balancer.
+func (c posClient) fetchPage(ctx context.Context, request posRequest) (response interface{}, err error) {
+	ctx, span := tracer.Start(ctx, "fetch POS page", trace.WithAttributes(
+		attribute.String("pos.path", request.Path),
+		attribute.Int("pos.limit", request.Limit),
+		attribute.Int("pos.offset", request.Offset),
+	))
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return c.pos(ctx, request)
+}
+
+// Business fetches the business record and decrypts its name, or returns
+// errUnknownBusiness when the POS does not know businessID.
+func (c posClient) Business(ctx context.Context, businessID string) (Business, error) {
+	request := posRequest{
+		Path:              businessesPath,
+		BusinessesRequest: BusinessesRequest{BusinessID: businessID},
+	}
+	response, err := c.fetchPage(ctx, request)
+	if err != nil {
+		return Business{}, err
+	}
+	business := response.(EncryptedBusiness)
+	if business.ID == "" {
+		return Business{}, errUnknownBusiness
+	}
+	return c.keys.decryptBusiness(business)
+}
+
+func (c posClient) Checks(ctx context.Context, filter BusinessesRequest) ([]Check, error) {
+	entities := make([]Check, 0)
+	err := c.fetchAll(ctx, checksPath, filter, func(response interface{}) int {
+		page := response.([]Check)
+		entities = append(entities, page...)
+		return len(page)
+	})
+	return entities, err
+}
+
+func (c posClient) Employees(ctx context.Context, filter BusinessesRequest) ([]Employee, error) {
+	entities := make([]Employee, 0)
+	err := c.fetchAll(ctx, employeesPath, filter, func(response interface{}) int {
+		page := response.([]Employee)
+		entities = append(entities, page...)
+		return len(page)
+	})
+	return entities, err
+}
+
+func (c posClient) LaborEntries(ctx context.Context, filter BusinessesRequest) ([]LaborEntry, error) {
+	entities := make([]LaborEntry, 0)
+	err := c.fetchAll(ctx, laborEntriesPath, filter, func(response interface{}) int {
+		page := response.([]LaborEntry)
+		entities = append(entities, page...)
+		return len(page)
+	})
+	return entities, err
+}
+
+func (c posClient) MenuItems(ctx context.Context, filter BusinessesRequest) ([]MenuItem, error) {
+	entities := make([]MenuItem, 0)
+	err := c.fetchAll(ctx, menuItemsPath, filter, func(response interface{}) int {
+		page := response.([]MenuItem)
+		entities = append(entities, page...)
+		return len(page)
+	})
+	return entities, err
+}
+
+func (c posClient) OrderedItems(ctx context.Context, filter BusinessesRequest) ([]OrderedItem, error) {
+	entities := make([]OrderedItem, 0)
+	err := c.fetchAll(ctx, orderedItemsPath, filter, func(response interface{}) int {
+		page := response.([]OrderedItem)
+		entities = append(entities, page...)
+		return len(page)
+	})
+	return entities, err
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakePOS serves a collection of total checks in pages, recording the
+// requests it got.
+type fakePOS struct {
+	total    int
+	requests []posRequest
+}
+
+func (p *fakePOS) endpoint(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(posRequest)
+	p.requests = append(p.requests, req)
+	n := p.total - req.Offset
+	if n > req.Limit {
+		n = req.Limit
+	}
+	if n < 0 {
+		n = 0
+	}
+	return make([]Check, n), nil
+}
+
+func TestFetchAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		maxPages int
+		offsets  []int
+		err      error
+	}{
+		{"empty", 0, 10, []int{0}, nil},
+		{"short page", 120, 10, []int{0}, nil},
+		{"several pages", 1200, 10, []int{0, 500, 1000}, nil},
+		{"whole pages end with an empty one", 1000, 10, []int{0, 500, 1000}, nil},
+		{"capped", 5000, 3, []int{0, 500, 1000}, errTooManyPages},
+		{"last page within the cap", 1400, 3, []int{0, 500, 1000}, nil},
+	}
+	for _, tt := range tests {
+		pos := &fakePOS{total: tt.total}
+		c := posClient{pos: pos.endpoint, maxPages: tt.maxPages}
+		filter := BusinessesRequest{BusinessID: "b"}
+		checks, err := c.Checks(context.Background(), filter)
+		if err != tt.err {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+		if err == nil && len(checks) != tt.total {
+			t.Errorf("%s: got %d checks, want %d", tt.name, len(checks), tt.total)
+		}
+		if len(pos.requests) != len(tt.offsets) {
+			t.Errorf("%s: got %d requests, want %d", tt.name, len(pos.requests), len(tt.offsets))
+			continue
+		}
+		for i, req := range pos.requests {
+			if req.Offset != tt.offsets[i] || req.Limit != posPageSize || req.Path != checksPath || req.BusinessID != "b" {
+				t.Errorf("%s: request %d = %+v", tt.name, i, req)
+			}
+		}
+	}
+}
+
+func TestFetchAllStopsWhenContextDone(t *testing.T) {
+	pos := &fakePOS{total: 5000}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := posClient{
+		pos: func(ctx context.Context, request interface{}) (interface{}, error) {
+			cancel()
+			return pos.endpoint(ctx, request)
+		},
+		maxPages: 100,
+	}
+	if _, err := c.Checks(ctx, BusinessesRequest{}); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if len(pos.requests) != 1 {
+		t.Errorf("got %d requests, want 1", len(pos.requests))
+	}
+}
+
+func TestCollectionFilter(t *testing.T) {
+	req := ReportRequest{
+		BusinessID: "b",
+		Start:      at("2018-11-12T00:00:00Z"),
+		End:        at("2018-11-19T00:00:00Z"),
+	}
+	tests := []struct {
+		collection               string
+		start, end               string
+		clockedStart, clockedEnd string
+	}{
+		{employeesPath, "", "", "", ""},
+		// shifts are fetched by overlap, however long before the report
+		// they were clocked in or created
+		{laborEntriesPath, "", "", "2018-11-12T00:00:00Z", "2018-11-19T00:00:00Z"},
+		{orderedItemsPath, "2018-11-12T00:00:00Z", "2018-11-19T00:00:00Z", "", ""},
+	}
+	for _, tt := range tests {
+		filter := collectionFilter(req, tt.collection)
+		if filter.BusinessID != "b" {
+			t.Errorf("%s: business = %q, want b", tt.collection, filter.BusinessID)
+		}
+		if got := formatOrEmpty(filter.CreatedStart); got != tt.start {
+			t.Errorf("%s: created start = %q, want %q", tt.collection, got, tt.start)
+		}
+		if got := formatOrEmpty(filter.CreatedEnd); got != tt.end {
+			t.Errorf("%s: created end = %q, want %q", tt.collection, got, tt.end)
+		}
+		if got := formatOrEmpty(filter.ClockedStart); got != tt.clockedStart {
+			t.Errorf("%s: clocked start = %q, want %q", tt.collection, got, tt.clockedStart)
+		}
+		if got := formatOrEmpty(filter.ClockedEnd); got != tt.clockedEnd {
+			t.Errorf("%s: clocked end = %q, want %q", tt.collection, got, tt.clockedEnd)
+		}
+	}
+}
+
+func formatOrEmpty(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -1,11 +1,9 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/ratelimit"
@@ -17,11 +15,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func proxyingMiddleware(ctx context.Context, instances string, logger log.Logger) ServiceMiddleware {
+func proxyingMiddleware(instances string, policy resiliencePolicy, maxPages int, keys keyring, metrics posMetrics, logger log.Logger) ServiceMiddleware {
 	// If instances is empty, don't proxy.
 	if instances == "" {
 		logger.Log("proxy_to", "none")
@@ -30,39 +29,49 @@ func proxyingMiddleware(ctx context.Context, instances string, logger log.Logger
 		logger.Log("proxy_to", "POS_APIs")
 	}
 
-	// Set some parameters for our client.
-	var (
-		qps         = 100                    // beyond which we will return an error
-		maxAttempts = 3                      // per request, before giving up
-		maxTime     = 250 * time.Millisecond // wallclock time, before giving up
-	)
-
 	// Otherwise, construct an endpoint for each instance in the list, and add
 	// it to a fixed set of endpoints. In a real service, rather than doing this
 	// by hand, you'd probably use package sd's support for your service
 	// discovery system.
+	// Every attempt takes a token from the limiter of its instance, and from
+	// the one of the POS as a whole, each allowing its QPS on average and in
+	// a burst of a second's worth.
 	var (
 		instanceList = split(instances)
 		endpointer   sd.FixedEndpointer
+		upstream     = ratelimit.NewErroringLimiter(newLimiter(policy.UpstreamQPS))
 	)
 	logger.Log("proxy_to", fmt.Sprint(instanceList))
 	for _, instance := range instanceList {
+		instancePolicy := policy.instance(instance)
 		var e endpoint.Endpoint
-		e = makePOSProxy(ctx, instance)
-		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(e)
-		e = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), qps))(e)
+		e = makePOSProxy(instance)
+		e = breaker(gobreaker.NewCircuitBreaker(metrics.watchBreaker(instancePolicy.Breaker.settings(instance))))(e)
+		e = upstream(e)
+		e = ratelimit.NewErroringLimiter(newLimiter(instancePolicy.QPS))(e)
+		e = metrics.instrument(instance)(e)
+		e = traceClient(instance)(e)
 		endpointer = append(endpointer, e)
 	}
 
 	// Now, build a single, retrying, load-balancing endpoint out of all of
 	// those individual endpoints.
 	balancer := lb.NewRoundRobin(endpointer)
-	retry := lb.Retry(maxAttempts, maxTime, balancer)
+	retry := retry(policy.Retry, balancer, metrics.retried)
 
 	// And finally, return the ServiceMiddleware, implemented by proxymw.
 	return func(next ReportingService) ReportingService {
-		return proxymw{ctx, next, retry}
+		return proxymw{next, posClient{retry, maxPages, keys}}
+	}
+}
+
+// newLimiter returns a limiter of qps requests per second, in bursts of up to
+// qps, or an unlimited one if qps is not positive.
+func newLimiter(qps int) *rate.Limiter {
+	if qps <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
 	}
+	return rate.NewLimiter(rate.Limit(qps), qps)
 }
 
 func split(s string) []string {
@@ -73,7 +82,24 @@ func split(s string) []string {
 	return a
 }
 
-func makePOSProxy(ctx context.Context, instance string) endpoint.Endpoint {
+// POS collections fetched through the proxy.
+const (
+	businessesPath   = "/businesses"
+	checksPath       = "/checks"
+	employeesPath    = "/employees"
+	laborEntriesPath = "/labor_entries"
+	menuItemsPath    = "/menu_items"
+	orderedItemsPath = "/ordered_items"
+)
+
+// posRequest is a request for one POS collection. It is sent to whichever
+// instance the load balancer picks, so the path is carried with the request.
+type posRequest struct {
+	Path string `json:"-"`
+	BusinessesRequest
+}
+
+func makePOSProxy(instance string) endpoint.Endpoint {
 	if !strings.HasPrefix(instance, "http") {
 		instance = "http://" + instance
 	}
@@ -81,53 +107,159 @@ func makePOSProxy(ctx context.Context, instance string) endpoint.Endpoint {
 	if err != nil {
 		panic(err)
 	}
-	if u.Path == "" {
-		u.Path = "/businesses"
-	}
 	return httptransport.NewClient(
-		"POST",
+		"GET",
 		u,
 		encodeRequest,
-		decodeBusinessesResponse,
+		decodePOSResponse,
+		httptransport.ClientBefore(injectTraceparent),
 	).Endpoint()
-	//TODO: use GET to pass limit (default=500) and offset (default=0) as query parameters
 }
 
+// encodeRequest passes limit, offset, business_id and the created date range
+// as query parameters. A zero limit or date is left out, so the POS applies
+// its default.
 func encodeRequest(_ context.Context, r *http.Request, request interface{}) error {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(request); err != nil {
-		return err
+	req := request.(posRequest)
+	r.URL.Path = req.Path
+	q := r.URL.Query()
+	if req.Limit > 0 {
+		q.Set("limit", strconv.Itoa(req.Limit))
+	}
+	q.Set("offset", strconv.Itoa(req.Offset))
+	q.Set("business_id", req.BusinessID)
+	if !req.CreatedStart.IsZero() {
+		q.Set("created_start", req.CreatedStart.Format(time.RFC3339Nano))
+	}
+	if !req.CreatedEnd.IsZero() {
+		q.Set("created_end", req.CreatedEnd.Format(time.RFC3339Nano))
+	}
+	if !req.ClockedStart.IsZero() {
+		q.Set("clocked_start", req.ClockedStart.Format(time.RFC3339Nano))
+	}
+	if !req.ClockedEnd.IsZero() {
+		q.Set("clocked_end", req.ClockedEnd.Format(time.RFC3339Nano))
 	}
-	r.Body = ioutil.NopCloser(&buf)
+	r.URL.RawQuery = q.Encode()
 	return nil
 }
 
-func decodeBusinessesResponse(_ context.Context, r *http.Response) (interface{}, error) {
-	//var response businessesResponse
-	var response Business
-	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
-		return nil, err
+// posStatusError is a POS answer other than 200 OK.
+type posStatusError struct {
+	path   string
+	status int
+	text   string // status line and body
+}
+
+func (e posStatusError) Error() string {
+	return fmt.Sprintf("POS %s: %s", e.path, e.text)
+}
+
+func decodePOSResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(r.Body)
+		return nil, posStatusError{r.Request.URL.Path, r.StatusCode, r.Status + ": " + strings.TrimSpace(string(body))}
 	}
-	return response, nil
+	switch r.Request.URL.Path {
+	case businessesPath:
+		var response EncryptedBusiness
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	case checksPath:
+		var response []Check
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	case employeesPath:
+		var response []Employee
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	case laborEntriesPath:
+		var response []LaborEntry
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	case menuItemsPath:
+		var response []MenuItem
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	case orderedItemsPath:
+		var response []OrderedItem
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	return nil, fmt.Errorf("POS %s: unexpected path", r.Request.URL.Path)
 }
 
 // proxymw implements ReportingService
 type proxymw struct {
-	ctx       context.Context
 	next      ReportingService
-	pos endpoint.Endpoint // client proxy
+	pos       posClient // client proxy
 }
 
-func (mw proxymw) reporting(req BusinessesRequest) (Business, error) {
-	response, err := mw.pos(mw.ctx, req)
+// reporting fetches the POS data of the requested business and hands it to
+// the next ReportingService to calculate the report. The fetches are given up
+// once ctx is done.
+func (mw proxymw) reporting(ctx context.Context, req ReportRequest) (Report, error) {
+	data, err := mw.fetchPOSData(ctx, req)
 	if err != nil {
-		fmt.Println("err in  (mw proxymw) reporting = ", err.Error())
-		return Business{}, err
+		return Report{}, err
 	}
-
-	var resp Business
-	resp = response.(Business)
-	return resp, nil
+	req.Data = data
+	return mw.next.reporting(ctx, req)
 }
 
+// fetchPOSData fetches the business and the POS collections the requested
+// report is calculated from.
+func (mw proxymw) fetchPOSData(ctx context.Context, req ReportRequest) (POSData, error) {
+	var (
+		data POSData
+		err  error
+	)
+	data.Business, err = mw.pos.Business(ctx, req.BusinessID)
+	if err != nil {
+		return POSData{}, err
+	}
 
+	for _, collection := range reportCollections[req.ReportType] {
+		filter := collectionFilter(req, collection)
+		switch collection {
+		case employeesPath:
+			data.Employees, err = mw.pos.Employees(ctx, filter)
+		case laborEntriesPath:
+			data.LaborEntries, err = mw.pos.LaborEntries(ctx, filter)
+		case orderedItemsPath:
+			data.OrderedItems, err = mw.pos.OrderedItems(ctx, filter)
+		}
+		if err != nil {
+			return POSData{}, err
+		}
+	}
+	return data, nil
+}
+
+// collectionFilter returns the filter the POS collection is fetched with for
+// req. Ordered items count towards the time frame they were ordered in. Labor
+// entries count towards the time frames their shift overlaps, so they are
+// fetched by their clock in and out rather than their creation: a shift of
+// any length, or entered after the fact, is fetched if it overlaps the report.
+func collectionFilter(req ReportRequest, collection string) BusinessesRequest {
+	filter := BusinessesRequest{BusinessID: req.BusinessID}
+	switch collection {
+	case laborEntriesPath:
+		filter.ClockedStart, filter.ClockedEnd = req.Start, req.End
+	case orderedItemsPath:
+		filter.CreatedStart, filter.CreatedEnd = req.Start, req.End
+	}
+	return filter
+}
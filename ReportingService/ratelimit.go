@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+// rateLimitedError is a /reporting request refused because the caller, or the
+// business it reports on, used up its token bucket. It is answered with 429
+// and a Retry-After of the wait until the next token.
+type rateLimitedError struct {
+	scope      string // "caller" or "business"
+	retryAfter time.Duration
+}
+
+func (e rateLimitedError) Error() string {
+	return fmt.Sprintf("client: %s rate limit exceeded, retry after %v", e.scope, e.retryAfter)
+}
+
+// bucketPolicy is a token bucket refilled with Rate tokens per second, up to
+// Burst. A zero Rate disables the bucket.
+type bucketPolicy struct {
+	Rate  float64
+	Burst int
+}
+
+// keyedLimiter keeps a token bucket per key, created on first use. Buckets
+// idle long enough to have filled up again are dropped, as a new one is the
+// same, so keys callers make up do not pile up.
+type keyedLimiter struct {
+	policy bucketPolicy
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func newKeyedLimiter(policy bucketPolicy) *keyedLimiter {
+	return &keyedLimiter{policy: policy, buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// reserve takes a token from the bucket of key, which refund gives back. If
+// there is none, nothing is taken and ok is false, with the wait until there
+// will be one.
+func (l *keyedLimiter) reserve(key string) (refund func(), wait time.Duration, ok bool) {
+	if l.policy.Rate <= 0 {
+		return func() {}, 0, true
+	}
+	now := time.Now()
+	r := l.bucket(key, now).ReserveN(now, 1)
+	if !r.OK() {
+		// a zero burst never lets anything through
+		return nil, time.Second, false
+	}
+	if wait = r.DelayFrom(now); wait > 0 {
+		r.CancelAt(now)
+		return nil, wait, false
+	}
+	// A reservation is only cancelled before the time it acts at, which for
+	// a token taken at once is now.
+	return func() { r.CancelAt(now) }, 0, true
+}
+
+func (l *keyedLimiter) bucket(key string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	refill := time.Duration(float64(l.policy.Burst) / l.policy.Rate * float64(time.Second))
+	if now.Sub(l.lastSweep) > refill {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastUsed) > refill {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(l.policy.Rate), l.policy.Burst)}
+		l.buckets[key] = b
+	}
+	b.lastUsed = now
+	return b.limiter
+}
+
+// limitingMiddleware shares the service fairly between callers: each
+// credential and each business reported on gets its own token bucket, and a
+// report takes a token from both.
+type limitingMiddleware struct {
+	callers    *keyedLimiter
+	businesses *keyedLimiter
+	next       ReportingService
+}
+
+func newLimitingMiddleware(callers, businesses bucketPolicy) ServiceMiddleware {
+	return func(next ReportingService) ReportingService {
+		return limitingMiddleware{newKeyedLimiter(callers), newKeyedLimiter(businesses), next}
+	}
+}
+
+func (mw limitingMiddleware) reporting(ctx context.Context, req ReportRequest) (Report, error) {
+	refundCaller, wait, ok := mw.callers.reserve(req.Caller.Name)
+	if !ok {
+		return Report{}, rateLimitedError{"caller", wait}
+	}
+	if _, wait, ok := mw.businesses.reserve(req.BusinessID); !ok {
+		// the report is refused, give the caller its token back
+		refundCaller()
+		return Report{}, rateLimitedError{"business", wait}
+	}
+	return mw.next.reporting(ctx, req)
+}
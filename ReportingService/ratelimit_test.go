@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingReports counts the reports that got through to it.
+type countingReports struct {
+	n *int
+}
+
+func (s countingReports) reporting(_ context.Context, req ReportRequest) (Report, error) {
+	*s.n++
+	return Report{BusinessID: req.BusinessID}, nil
+}
+
+func report(svc ReportingService, caller, businessID string) error {
+	_, err := svc.reporting(context.Background(), ReportRequest{Caller: Caller{Name: caller}, BusinessID: businessID})
+	return err
+}
+
+func TestLimitingRefundsCallerToken(t *testing.T) {
+	var n int
+	// next to no refill during the test
+	svc := newLimitingMiddleware(bucketPolicy{0.001, 2}, bucketPolicy{0.001, 1})(countingReports{&n})
+
+	steps := []struct {
+		caller, business string
+		scope            string // of the rate limit hit, "" if none
+	}{
+		{"a", "x", ""},
+		{"a", "x", "business"}, // refused, a gets its token back
+		{"a", "y", ""},         // so a still has one for y
+		{"a", "z", "caller"},
+		{"b", "z", ""}, // callers have their own buckets
+	}
+	for i, step := range steps {
+		err := report(svc, step.caller, step.business)
+		var scope string
+		if limited, ok := err.(rateLimitedError); ok {
+			scope = limited.scope
+			if limited.retryAfter <= 0 {
+				t.Errorf("step %d: retry after %v", i, limited.retryAfter)
+			}
+		} else if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if scope != step.scope {
+			t.Errorf("step %d: %s on %s limited by %q, want %q", i, step.caller, step.business, scope, step.scope)
+		}
+	}
+	if n != 3 {
+		t.Errorf("%d reports got through, want 3", n)
+	}
+}
+
+func TestLimitingDisabled(t *testing.T) {
+	var n int
+	svc := newLimitingMiddleware(bucketPolicy{}, bucketPolicy{})(countingReports{&n})
+	for i := 0; i < 100; i++ {
+		if err := report(svc, "a", "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 100 {
+		t.Errorf("%d reports got through, want 100", n)
+	}
+}
+
+func TestKeyedLimiterWait(t *testing.T) {
+	l := newKeyedLimiter(bucketPolicy{Rate: 2, Burst: 1})
+	if _, _, ok := l.reserve("a"); !ok {
+		t.Fatal("first token refused")
+	}
+	_, wait, ok := l.reserve("a")
+	if ok {
+		t.Fatal("second token given")
+	}
+	if wait <= 0 || wait > 500*time.Millisecond {
+		t.Errorf("wait = %v, want up to 500ms", wait)
+	}
+	// a refused reservation takes nothing: the next token comes no later
+	if _, again, _ := l.reserve("a"); again > wait {
+		t.Errorf("wait grew from %v to %v", wait, again)
+	}
+}
+
+func TestKeyedLimiterZeroBurst(t *testing.T) {
+	l := newKeyedLimiter(bucketPolicy{Rate: 1, Burst: 0})
+	if _, wait, ok := l.reserve("a"); ok || wait != time.Second {
+		t.Errorf("reserve = %v, %v, want refused for a second", ok, wait)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
 	"net/http"
 	"time"
 )
@@ -12,22 +13,78 @@ import (
 var (
 	errMissingParam 	= errors.New("missing param")
 	errBadInput        	= errors.New("client: bad input")
-	errBadCredential    = errors.New("client: HTTP Basic Auth: bad credential")
+	errBadCredential    = errors.New("client: bad credential")
+	errForbidden        = errors.New("client: business out of the credential's scope")
+	errUnknownBusiness  = errors.New("unknown business")
+	errUnknownReport    = errors.New("client: unknown report type")
 )
 
 // ReportingService retreives the source data from POS APIs,
 // and implement a reporting API to calculate and deliver a number of common metrics.
 type ReportingService interface {
-	reporting(BusinessesRequest) (Business, error)
+	reporting(context.Context, ReportRequest) (Report, error)
 }
 
 type reportingService struct{}
 
-func (reportingService) reporting(req BusinessesRequest) (Business, error) {
+// reporting calculates the requested report from the POS data attached to
+// the request by the proxying middleware.
+func (reportingService) reporting(_ context.Context, req ReportRequest) (Report, error) {
 	if req.BusinessID == "" {
-		return Business{}, ErrEmpty
+		return Report{}, ErrEmpty
+	}
+	req.Location = reportLocation(req)
+	if req.Now.IsZero() {
+		req.Now = time.Now()
+	}
+	switch req.ReportType {
+	case reportLCP:
+		return laborCostPercentage(req), nil
+	case reportFCP:
+		return foodCostPercentage(req), nil
+	case reportEGS:
+		return employeeGrossSales(req), nil
+	}
+	return Report{}, errUnknownReport
+}
+
+// requestTimeoutHeader carries how long a /reporting consumer waits for the
+// report, as a duration such as "2s" or "500ms". The POS fetches are given up
+// when it elapses.
+const requestTimeoutHeader = "X-Request-Timeout"
+
+type contextKey int
+
+const (
+	requestTimeoutKey contextKey = iota
+	requestLabelsKey
+	callerErrKey // Err of the context of a POS request, before the retry policy bounds it
+)
+
+// requestTimeoutToContext is the httptransport.ServerBefore moving the
+// requestTimeoutHeader into the context, for applyRequestTimeout.
+func requestTimeoutToContext(ctx context.Context, r *http.Request) context.Context {
+	if v := r.Header.Get(requestTimeoutHeader); v != "" {
+		return context.WithValue(ctx, requestTimeoutKey, v)
+	}
+	return ctx
+}
+
+// applyRequestTimeout bounds the context of the endpoint by the request
+// timeout, if the consumer sent one.
+func applyRequestTimeout(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if v, ok := ctx.Value(requestTimeoutKey).(string); ok {
+			timeout, err := time.ParseDuration(v)
+			if err != nil || timeout <= 0 {
+				return nil, errBadInput
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return next(ctx, request)
 	}
-	return Business{}, nil //TODO : provides real POS operation
 }
 
 // ErrEmpty is returned when an input string is empty.
@@ -35,10 +92,10 @@ var ErrEmpty = errors.New("empty string")
 
 func makePOSEndpoint(svc ReportingService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req := request.(BusinessesRequest)
-		v, err := svc.reporting(req)
+		req := request.(ReportRequest)
+		v, err := svc.reporting(ctx, req)
 		if err != nil {
-			return Business{}, nil
+			return nil, err
 		}
 		return v, nil
 	}
@@ -52,14 +109,47 @@ type BusinessesRequest struct {
 	Limit int `json:"limit"`
 	Offset int `json:"offset"`
 	BusinessID string `json:"business_id"`
+	CreatedStart time.Time `json:"created_start"`
+	CreatedEnd time.Time `json:"created_end"`
+	ClockedStart time.Time `json:"clocked_start"` // labor entries on shift in the range
+	ClockedEnd time.Time `json:"clocked_end"`
 }
 
 //A consumer should be able to provide a date range, a bucketing time interval, a business id, and a report type (defined below).
+//The date range is either Start and End, or the whole days DaysAfter StartDate.
 type BusinessIDStartDateDaysAfterStartRequest struct {
 	BusinessID 	string `json:"business_id"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
 	StartDate           time.Time `json:"start_date"`
 	DaysAfter 	int		`json:"days_after_start_date"`
 	ReportType 	string	`json:"report_type"`
+	TimeInterval	string	`json:"time_interval"`
+	Timezone	string	`json:"timezone"` // overrides the business time zone
+}
+
+// ReportRequest is the validated form of a /reporting request passed down the
+// ReportingService middleware chain. Data is filled in by the proxying
+// middleware before the report is calculated. Location is nil unless the
+// consumer overrides the business time zone.
+type ReportRequest struct {
+	Caller       Caller
+	BusinessID   string
+	ReportType   string
+	TimeInterval string
+	Start        time.Time
+	End          time.Time
+	Location     *time.Location
+	Now          time.Time // shifts still clocked in count until then
+	Data         POSData
+}
+
+// POSData holds the source POS records a report is calculated from.
+type POSData struct {
+	Business     Business
+	Employees    []Employee
+	LaborEntries []LaborEntry
+	OrderedItems []OrderedItem
 }
 
 //limit (number) - The amount of results to return is 100 by default and and the max is 500.
@@ -79,31 +169,76 @@ func decodeBusinessesRequest(_ context.Context, r *http.Request) (interface{}, e
 	return request, nil
 }
 
-func decodeBusinessIDStartDateDaysAfterStartRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	err := checkHTTPBasicAuth(r)
-	if err != nil {
-		return nil, err
-	}
-	var request BusinessIDStartDateDaysAfterStartRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
-	}
+// decodeBusinessIDStartDateDaysAfterStartRequest returns the /reporting
+// request decoder, which authenticates callers with auth and rejects date
+// ranges longer than maxRange.
+func decodeBusinessIDStartDateDaysAfterStartRequest(auth Authenticator, maxRange time.Duration) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		caller, err := auth.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		var request BusinessIDStartDateDaysAfterStartRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return nil, httpError{http.StatusBadRequest, err}
+		}
 
-	proxyRequest := BusinessesRequest {
-		Limit : 500,
-		Offset: 0,
-		BusinessID: request.BusinessID,
+		if request.BusinessID == "" {
+			return nil, ErrEmpty
+		}
+		if request.ReportType == "" {
+			return nil, errMissingParam
+		}
+		if _, ok := reportCollections[request.ReportType]; !ok {
+			return nil, errUnknownReport
+		}
+		if request.TimeInterval == "" {
+			request.TimeInterval = intervalDay
+		}
+		if !validInterval(request.TimeInterval) {
+			return nil, errBadInput
+		}
+		start, end, err := dateRange(request)
+		if err != nil {
+			return nil, err
+		}
+		if !end.After(start) || end.Sub(start) > maxRange {
+			return nil, errBadInput
+		}
+
+		var loc *time.Location
+		if request.Timezone != "" {
+			if loc, err = time.LoadLocation(request.Timezone); err != nil {
+				return nil, errBadInput
+			}
+		}
+
+		reportRequest := ReportRequest{
+			Caller:       caller,
+			BusinessID:   request.BusinessID,
+			ReportType:   request.ReportType,
+			TimeInterval: request.TimeInterval,
+			Start:        start,
+			End:          end,
+			Location:     loc,
+		}
+		return reportRequest, nil
 	}
-	return proxyRequest, nil
 }
 
-//base64 encoded "posUser:posPassword" = cG9zVXNlcjpwb3NQYXNzd29yZA==
-func checkHTTPBasicAuth(r *http.Request) error {
-	user, pass, ok := r.BasicAuth()
-	if !ok || user != "posUser" || pass != "posPassword" {
-		return errBadCredential
+// dateRange returns the requested [start, end) range, taken from start and end
+// when given, or else from start_date and days_after_start_date.
+func dateRange(request BusinessIDStartDateDaysAfterStartRequest) (start, end time.Time, err error) {
+	switch {
+	case !request.Start.IsZero() || !request.End.IsZero():
+		if request.Start.IsZero() || request.End.IsZero() {
+			return time.Time{}, time.Time{}, errMissingParam
+		}
+		return request.Start, request.End, nil
+	case !request.StartDate.IsZero():
+		return request.StartDate, request.StartDate.AddDate(0, 0, request.DaysAfter), nil
 	}
-	return nil
+	return time.Time{}, time.Time{}, errMissingParam
 }
 
 func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    BusinessIDStartDateDaysAfterStartRequest
+		start, end string
+		err        error
+	}{
+		{
+			name:    "start and end",
+			request: BusinessIDStartDateDaysAfterStartRequest{Start: at("2018-11-12T10:00:00Z"), End: at("2018-11-13T10:00:00Z")},
+			start:   "2018-11-12T10:00:00Z",
+			end:     "2018-11-13T10:00:00Z",
+		},
+		{
+			name:    "days after start date",
+			request: BusinessIDStartDateDaysAfterStartRequest{StartDate: at("2018-11-12T10:00:00Z"), DaysAfter: 20},
+			start:   "2018-11-12T10:00:00Z",
+			end:     "2018-12-02T10:00:00Z",
+		},
+		{
+			name: "start and end win over start date",
+			request: BusinessIDStartDateDaysAfterStartRequest{
+				Start: at("2018-11-12T10:00:00Z"), End: at("2018-11-13T10:00:00Z"),
+				StartDate: at("2018-01-01T00:00:00Z"), DaysAfter: 1,
+			},
+			start: "2018-11-12T10:00:00Z",
+			end:   "2018-11-13T10:00:00Z",
+		},
+		{
+			name:    "start without end",
+			request: BusinessIDStartDateDaysAfterStartRequest{Start: at("2018-11-12T10:00:00Z")},
+			err:     errMissingParam,
+		},
+		{
+			name:    "end without start",
+			request: BusinessIDStartDateDaysAfterStartRequest{End: at("2018-11-12T10:00:00Z")},
+			err:     errMissingParam,
+		},
+		{
+			name: "no range",
+			err:  errMissingParam,
+		},
+	}
+	for _, tt := range tests {
+		start, end, err := dateRange(tt.request)
+		if err != tt.err {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !start.Equal(at(tt.start)) || !end.Equal(at(tt.end)) {
+			t.Errorf("%s: range = %s - %s, want %s - %s", tt.name,
+				start.Format(time.RFC3339), end.Format(time.RFC3339), tt.start, tt.end)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"gopkg.in/inf.v0"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report types accepted in BusinessIDStartDateDaysAfterStartRequest.ReportType
+const (
+	reportLCP = "LCP" // Labor Cost Percentage
+	reportFCP = "FCP" // Food Cost Percentage
+	reportEGS = "EGS" // Employee Gross Sales
+)
+
+// reportCollections lists the POS collections each report is calculated from.
+var reportCollections = map[string][]string{
+	reportLCP: {laborEntriesPath, orderedItemsPath},
+	reportFCP: {orderedItemsPath},
+	reportEGS: {employeesPath, orderedItemsPath},
+}
+
+// Report is the payload returned by the /reporting endpoint.
+type Report struct {
+	BusinessID   string         `json:"business_id"`
+	Report       string         `json:"report"`
+	TimeInterval string         `json:"time_interval"`
+	TimeZone     string         `json:"time_zone"`
+	Data         []ReportBucket `json:"data"`
+}
+
+// TimeFrame is the half-open interval [Start, End) a report value covers.
+type TimeFrame struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ReportBucket is a single metric value of a report. EmployeeID and Employee
+// are only set by per-employee reports.
+type ReportBucket struct {
+	TimeFrame  TimeFrame `json:"time_frame"`
+	EmployeeID string    `json:"employee_id,omitempty"`
+	Employee   string    `json:"employee,omitempty"`
+	Value      *inf.Dec  `json:"value"`
+}
+
+var (
+	decZero    = inf.NewDec(0, 2)
+	decHundred = inf.NewDec(100, 0)
+	secPerHour = inf.NewDec(3600, 0)
+)
+
+// Reports sort the records they are calculated from once and sweep them
+// across the buckets, rather than scan every record for every bucket, so an
+// hourly report over a long range costs O(n log n) in its n records plus its
+// buckets, not their product.
+
+// laborCostPercentage calculates the labor cost over the net sales of each
+// bucket, as a percentage. Shifts still clocked in count until req.Now.
+func laborCostPercentage(req ReportRequest) Report {
+	frames := timeFrames(req.Start, req.End, req.TimeInterval, req.Location)
+	labor := laborCosts(req.Data.LaborEntries, frames, req.Now)
+	items := itemsByFrame(req.Data.OrderedItems, frames)
+	data := make([]ReportBucket, 0, len(frames))
+	for i, tf := range frames {
+		sales := netSales(items[i], tf)
+		data = append(data, ReportBucket{TimeFrame: tf, Value: percentage(labor[i], sales)})
+	}
+
+	return Report{
+		BusinessID:   req.BusinessID,
+		Report:       reportLCP,
+		TimeInterval: req.TimeInterval,
+		TimeZone:     req.Location.String(),
+		Data:         data,
+	}
+}
+
+// foodCostPercentage calculates the cost of the non-voided items ordered in
+// each bucket over their price, as a percentage.
+func foodCostPercentage(req ReportRequest) Report {
+	frames := timeFrames(req.Start, req.End, req.TimeInterval, req.Location)
+	items := itemsByFrame(req.Data.OrderedItems, frames)
+	data := make([]ReportBucket, 0, len(frames))
+	for i, tf := range frames {
+		cost := foodCost(items[i], tf)
+		sales := netSales(items[i], tf)
+		data = append(data, ReportBucket{TimeFrame: tf, Value: percentage(cost, sales)})
+	}
+
+	return Report{
+		BusinessID:   req.BusinessID,
+		Report:       reportFCP,
+		TimeInterval: req.TimeInterval,
+		TimeZone:     req.Location.String(),
+		Data:         data,
+	}
+}
+
+// employeeGrossSales attributes the price of the non-voided items ordered in
+// each bucket to the employee who took the order. Every employee of the
+// business gets a value in every bucket, ordered by name.
+func employeeGrossSales(req ReportRequest) Report {
+	frames := timeFrames(req.Start, req.End, req.TimeInterval, req.Location)
+	items := itemsByFrame(req.Data.OrderedItems, frames)
+	sales := make([]map[string]*inf.Dec, len(frames))
+	for i, tf := range frames {
+		sales[i] = grossSalesByEmployee(items[i], tf)
+	}
+
+	names := make(map[string]string, len(req.Data.Employees))
+	for _, emp := range req.Data.Employees {
+		names[emp.ID] = employeeName(emp)
+	}
+	// sales taken by employees the POS did not list are reported by ID
+	for _, totals := range sales {
+		for id := range totals {
+			if _, ok := names[id]; !ok {
+				names[id] = id
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if names[ids[i]] != names[ids[j]] {
+			return names[ids[i]] < names[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	data := make([]ReportBucket, 0, len(frames)*len(ids))
+	for i, tf := range frames {
+		for _, id := range ids {
+			value, ok := sales[i][id]
+			if !ok {
+				value = new(inf.Dec).Set(decZero)
+			}
+			data = append(data, ReportBucket{TimeFrame: tf, EmployeeID: id, Employee: names[id], Value: value})
+		}
+	}
+
+	return Report{
+		BusinessID:   req.BusinessID,
+		Report:       reportEGS,
+		TimeInterval: req.TimeInterval,
+		TimeZone:     req.Location.String(),
+		Data:         data,
+	}
+}
+
+// itemsByFrame returns the items ordered inside each of frames, which are
+// consecutive as timeFrames returns them, as slices of a copy of items sorted
+// by the time they were ordered.
+func itemsByFrame(items []OrderedItem, frames []TimeFrame) [][]OrderedItem {
+	sorted := append([]OrderedItem(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.Before(sorted[j].CreatedAt) })
+	byFrame := make([][]OrderedItem, len(frames))
+	next := 0
+	for i, tf := range frames {
+		for next < len(sorted) && sorted[next].CreatedAt.Before(tf.Start) {
+			next++
+		}
+		first := next
+		for next < len(sorted) && sorted[next].CreatedAt.Before(tf.End) {
+			next++
+		}
+		byFrame[i] = sorted[first:next]
+	}
+	return byFrame
+}
+
+// laborCosts returns the labor cost of each of frames, which are consecutive
+// as timeFrames returns them. The entries are sorted by clock in and swept
+// across the frames, each frame costing only the shifts overlapping it.
+func laborCosts(entries []LaborEntry, frames []TimeFrame, now time.Time) []*inf.Dec {
+	sorted := make([]LaborEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.PayRate != nil {
+			sorted = append(sorted, entry)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ClockIn.Before(sorted[j].ClockIn) })
+
+	costs := make([]*inf.Dec, len(frames))
+	var onShift []LaborEntry // clocked in before the frame ends, out after it starts
+	next := 0
+	for i, tf := range frames {
+		still := onShift[:0]
+		for _, entry := range onShift {
+			if clockOut(entry, now).After(tf.Start) {
+				still = append(still, entry)
+			}
+		}
+		onShift = still
+		for next < len(sorted) && sorted[next].ClockIn.Before(tf.End) {
+			if clockOut(sorted[next], now).After(tf.Start) {
+				onShift = append(onShift, sorted[next])
+			}
+			next++
+		}
+		costs[i] = laborCost(onShift, tf, now)
+	}
+	return costs
+}
+
+// clockOut returns the end of the shift of entry: its clock out, or now if it
+// is still clocked in.
+func clockOut(entry LaborEntry, now time.Time) time.Time {
+	if entry.ClockOut.IsZero() {
+		return now
+	}
+	return entry.ClockOut
+}
+
+// laborCost sums clocked hours times pay rate of the labor entries, counting
+// only the part of each shift which falls inside tf. Shifts still clocked in
+// count until now, so the cost of the current bucket grows as they go on;
+// with a zero now they count for nothing.
+func laborCost(entries []LaborEntry, tf TimeFrame, now time.Time) *inf.Dec {
+	total := new(inf.Dec).Set(decZero)
+	for _, entry := range entries {
+		if entry.PayRate == nil {
+			continue
+		}
+		in, out := entry.ClockIn, clockOut(entry, now)
+		if in.Before(tf.Start) {
+			in = tf.Start
+		}
+		if out.After(tf.End) {
+			out = tf.End
+		}
+		if !out.After(in) {
+			continue
+		}
+		seconds := inf.NewDec(int64(out.Sub(in)/time.Second), 0)
+		cost := new(inf.Dec).Mul(entry.PayRate, seconds)
+		total.Add(total, cost.QuoRound(cost, secPerHour, 2, inf.RoundHalfUp))
+	}
+	return total
+}
+
+// netSales sums the price of the non-voided items ordered inside tf.
+func netSales(items []OrderedItem, tf TimeFrame) *inf.Dec {
+	total := new(inf.Dec).Set(decZero)
+	for _, item := range items {
+		if item.Voided || item.Price == nil || !tf.contains(item.CreatedAt) {
+			continue
+		}
+		total.Add(total, item.Price)
+	}
+	return total
+}
+
+// foodCost sums the cost of the non-voided items ordered inside tf.
+func foodCost(items []OrderedItem, tf TimeFrame) *inf.Dec {
+	total := new(inf.Dec).Set(decZero)
+	for _, item := range items {
+		if item.Voided || item.Cost == nil || !tf.contains(item.CreatedAt) {
+			continue
+		}
+		total.Add(total, item.Cost)
+	}
+	return total
+}
+
+// grossSalesByEmployee sums the price of the non-voided items ordered inside
+// tf by employee ID.
+func grossSalesByEmployee(items []OrderedItem, tf TimeFrame) map[string]*inf.Dec {
+	totals := make(map[string]*inf.Dec)
+	for _, item := range items {
+		if item.Voided || item.Price == nil || !tf.contains(item.CreatedAt) {
+			continue
+		}
+		total, ok := totals[item.EmployeeID]
+		if !ok {
+			total = new(inf.Dec).Set(decZero)
+			totals[item.EmployeeID] = total
+		}
+		total.Add(total, item.Price)
+	}
+	return totals
+}
+
+func employeeName(emp Employee) string {
+	return strings.TrimSpace(emp.FirstName + " " + emp.LastName)
+}
+
+// percentage returns part / whole * 100 rounded to 2 decimals,
+// or 0 when whole is 0.
+func percentage(part, whole *inf.Dec) *inf.Dec {
+	if whole.Sign() == 0 {
+		return new(inf.Dec).Set(decZero)
+	}
+	scaled := new(inf.Dec).Mul(part, decHundred)
+	return scaled.QuoRound(scaled, whole, 2, inf.RoundHalfUp)
+}
+
+func (tf TimeFrame) contains(t time.Time) bool {
+	return !t.Before(tf.Start) && t.Before(tf.End)
+}
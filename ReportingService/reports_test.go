@@ -0,0 +1,230 @@
+package main
+
+import (
+	"gopkg.in/inf.v0"
+	"testing"
+	"time"
+)
+
+func dec(s string) *inf.Dec {
+	d, ok := new(inf.Dec).SetString(s)
+	if !ok {
+		panic("bad decimal " + s)
+	}
+	return d
+}
+
+func at(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// values returns the bucket values of report as strings, for comparison.
+func values(report Report) []string {
+	v := make([]string, len(report.Data))
+	for i, bucket := range report.Data {
+		v[i] = bucket.Value.String()
+	}
+	return v
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLaborCostPercentage(t *testing.T) {
+	req := ReportRequest{
+		BusinessID:   "b",
+		TimeInterval: intervalDay,
+		Start:        at("2018-11-12T00:00:00Z"),
+		End:          at("2018-11-15T00:00:00Z"),
+		Location:     time.UTC,
+		Now:          at("2018-11-13T12:00:00Z"),
+		Data: POSData{
+			LaborEntries: []LaborEntry{
+				// still clocked in: 2h until now on the 13th at 10.00
+				{ClockIn: at("2018-11-13T10:00:00Z"), PayRate: dec("10.00")},
+				// 4h at 15.00 on the 12th
+				{ClockIn: at("2018-11-12T10:00:00Z"), ClockOut: at("2018-11-12T14:00:00Z"), PayRate: dec("15.00")},
+				// overnight: 2h on the 12th and 3h on the 13th at 10.00
+				{ClockIn: at("2018-11-12T22:00:00Z"), ClockOut: at("2018-11-13T03:00:00Z"), PayRate: dec("10.00")},
+				// no pay rate
+				{ClockIn: at("2018-11-13T10:00:00Z"), ClockOut: at("2018-11-13T12:00:00Z")},
+			},
+			OrderedItems: []OrderedItem{
+				{Price: dec("90.00"), CreatedAt: at("2018-11-13T01:00:00Z")},
+				{Price: dec("200.00"), CreatedAt: at("2018-11-12T12:00:00Z")},
+				{Price: dec("50.00"), Voided: true, CreatedAt: at("2018-11-12T12:00:00Z")},
+			},
+		},
+	}
+
+	report := laborCostPercentage(req)
+	// (60.00 + 20.00) / 200.00, (30.00 + 20.00) / 90.00, and no sales on the 14th
+	want := []string{"40.00", "55.56", "0.00"}
+	if got := values(report); !equal(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+	if report.Report != reportLCP || report.TimeZone != "UTC" || report.TimeInterval != intervalDay {
+		t.Errorf("report = %s %s %s", report.Report, report.TimeZone, report.TimeInterval)
+	}
+}
+
+func TestLaborCostRoundsEachEntry(t *testing.T) {
+	tf := TimeFrame{at("2018-11-12T00:00:00Z"), at("2018-11-13T00:00:00Z")}
+	entries := []LaborEntry{
+		// 20 minutes at 10.01 is 3.336..., rounded to 3.34
+		{ClockIn: at("2018-11-12T10:00:00Z"), ClockOut: at("2018-11-12T10:20:00Z"), PayRate: dec("10.01")},
+	}
+	if got := laborCost(entries, tf, time.Time{}).String(); got != "3.34" {
+		t.Errorf("laborCost = %s, want 3.34", got)
+	}
+}
+
+func TestLaborCostsSweep(t *testing.T) {
+	frames := timeFrames(at("2018-11-12T08:00:00Z"), at("2018-11-12T14:00:00Z"), intervalHour, time.UTC)
+	entries := []LaborEntry{
+		// clocked in at 12:30 and still on shift: half an hour until now
+		{ClockIn: at("2018-11-12T12:30:00Z"), PayRate: dec("10.00")},
+		// 09:00 to 11:00 at 10.00, and 09:30 to 10:30 at 20.00
+		{ClockIn: at("2018-11-12T09:00:00Z"), ClockOut: at("2018-11-12T11:00:00Z"), PayRate: dec("10.00")},
+		{ClockIn: at("2018-11-12T09:30:00Z"), ClockOut: at("2018-11-12T10:30:00Z"), PayRate: dec("20.00")},
+		// over before the range
+		{ClockIn: at("2018-11-12T05:00:00Z"), ClockOut: at("2018-11-12T07:00:00Z"), PayRate: dec("10.00")},
+	}
+	costs := laborCosts(entries, frames, at("2018-11-12T13:00:00Z"))
+	got := make([]string, len(costs))
+	for i, c := range costs {
+		got[i] = c.String()
+	}
+	if want := []string{"0.00", "20.00", "20.00", "0.00", "5.00", "0.00"}; !equal(got, want) {
+		t.Errorf("costs = %v, want %v", got, want)
+	}
+}
+
+func TestItemsByFrame(t *testing.T) {
+	frames := timeFrames(at("2018-11-12T00:00:00Z"), at("2018-11-15T00:00:00Z"), intervalDay, time.UTC)
+	items := []OrderedItem{
+		{ID: "d", CreatedAt: at("2018-11-14T23:59:59Z")},
+		{ID: "a", CreatedAt: at("2018-11-11T23:00:00Z")},
+		{ID: "c", CreatedAt: at("2018-11-12T00:00:00Z")},
+		{ID: "e", CreatedAt: at("2018-11-15T00:00:00Z")},
+		{ID: "b", CreatedAt: at("2018-11-12T18:00:00Z")},
+	}
+	byFrame := itemsByFrame(items, frames)
+	got := make([]string, len(byFrame))
+	for i, frame := range byFrame {
+		for _, item := range frame {
+			got[i] += item.ID
+		}
+	}
+	if want := []string{"cb", "", "d"}; !equal(got, want) {
+		t.Errorf("items by frame = %q, want %q", got, want)
+	}
+	if items[0].ID != "d" {
+		t.Error("sorted the items in place")
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	tests := []struct {
+		part, whole, want string
+	}{
+		{"1", "3", "33.33"},
+		{"2", "3", "66.67"},
+		{"5.00", "0.00", "0.00"},
+		{"150", "100", "150.00"},
+	}
+	for _, tt := range tests {
+		if got := percentage(dec(tt.part), dec(tt.whole)).String(); got != tt.want {
+			t.Errorf("percentage(%s, %s) = %s, want %s", tt.part, tt.whole, got, tt.want)
+		}
+	}
+}
+
+func TestFoodCostPercentage(t *testing.T) {
+	req := ReportRequest{
+		BusinessID:   "b",
+		TimeInterval: intervalDay,
+		Start:        at("2018-11-12T00:00:00Z"),
+		End:          at("2018-11-14T00:00:00Z"),
+		Location:     time.UTC,
+		Data: POSData{
+			OrderedItems: []OrderedItem{
+				{Cost: dec("3.00"), Price: dec("10.00"), CreatedAt: at("2018-11-12T12:00:00Z")},
+				{Cost: dec("4.50"), Price: dec("12.00"), CreatedAt: at("2018-11-12T13:00:00Z")},
+				{Cost: dec("9.00"), Price: dec("10.00"), Voided: true, CreatedAt: at("2018-11-12T14:00:00Z")},
+				// ordered before the range
+				{Cost: dec("9.00"), Price: dec("10.00"), CreatedAt: at("2018-11-11T23:59:59Z")},
+			},
+		},
+	}
+
+	report := foodCostPercentage(req)
+	// 7.50 / 22.00, and no sales on the 13th
+	want := []string{"34.09", "0.00"}
+	if got := values(report); !equal(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+	if report.Report != reportFCP {
+		t.Errorf("report = %s, want %s", report.Report, reportFCP)
+	}
+}
+
+func TestEmployeeGrossSales(t *testing.T) {
+	req := ReportRequest{
+		BusinessID:   "b",
+		TimeInterval: intervalDay,
+		Start:        at("2018-11-12T00:00:00Z"),
+		End:          at("2018-11-14T00:00:00Z"),
+		Location:     time.UTC,
+		Data: POSData{
+			Employees: []Employee{
+				{ID: "e2", FirstName: "Mary", LastName: "Smith"},
+				{ID: "e1", FirstName: "John", LastName: "Doe"},
+			},
+			OrderedItems: []OrderedItem{
+				{EmployeeID: "e1", Price: dec("10.00"), CreatedAt: at("2018-11-12T12:00:00Z")},
+				{EmployeeID: "e1", Price: dec("5.50"), CreatedAt: at("2018-11-12T13:00:00Z")},
+				{EmployeeID: "e2", Price: dec("7.00"), Voided: true, CreatedAt: at("2018-11-12T13:00:00Z")},
+				{EmployeeID: "e2", Price: dec("8.00"), CreatedAt: at("2018-11-13T13:00:00Z")},
+				// taken by an employee the POS did not list
+				{EmployeeID: "e3", Price: dec("1.00"), CreatedAt: at("2018-11-13T14:00:00Z")},
+			},
+		},
+	}
+
+	report := employeeGrossSales(req)
+	want := []struct {
+		start, id, name, value string
+	}{
+		{"2018-11-12T00:00:00Z", "e1", "John Doe", "15.50"},
+		{"2018-11-12T00:00:00Z", "e2", "Mary Smith", "0.00"},
+		{"2018-11-12T00:00:00Z", "e3", "e3", "0.00"},
+		{"2018-11-13T00:00:00Z", "e1", "John Doe", "0.00"},
+		{"2018-11-13T00:00:00Z", "e2", "Mary Smith", "8.00"},
+		{"2018-11-13T00:00:00Z", "e3", "e3", "1.00"},
+	}
+	if len(report.Data) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(report.Data), len(want))
+	}
+	for i, w := range want {
+		got := report.Data[i]
+		if !got.TimeFrame.Start.Equal(at(w.start)) || got.EmployeeID != w.id || got.Employee != w.name || got.Value.String() != w.value {
+			t.Errorf("bucket %d = %s %s %q %s, want %s %s %q %s", i,
+				got.TimeFrame.Start.Format(time.RFC3339), got.EmployeeID, got.Employee, got.Value,
+				w.start, w.id, w.name, w.value)
+		}
+	}
+}
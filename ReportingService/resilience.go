@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/sd/lb"
+	"github.com/sony/gobreaker"
+	"math/rand"
+	"net/url"
+	"os"
+	"time"
+)
+
+// resiliencePolicy is how the POS proxy copes with failing or slow POS
+// instances. It is set by flags, which a JSON file may override, per POS
+// instance if need be:
+//
+//	{"upstream_qps": 150, "retry": {"max_attempts": 4, "backoff": "50ms"},
+//	 "instances": {"pos-a:8091": {"qps": 50, "breaker": {"consecutive_failures": 3}}}}
+type resiliencePolicy struct {
+	UpstreamQPS int                       `json:"upstream_qps"` // to all instances together, beyond which requests are rejected
+	Retry       retryPolicy               `json:"retry"`
+	Default     instancePolicy            `json:"default"`
+	Instances   map[string]instancePolicy `json:"-"`
+}
+
+// retryPolicy applies to a POS request as a whole, whichever instances its
+// attempts are sent to.
+type retryPolicy struct {
+	MaxAttempts int      `json:"max_attempts"` // per request, before giving up
+	MaxTime     duration `json:"max_time"`     // wallclock time of all attempts, before giving up
+	Backoff     duration `json:"backoff"`      // wait before the first retry, doubled for each one after
+	MaxBackoff  duration `json:"max_backoff"`  // longest wait between attempts, 0 for no wait
+}
+
+// instancePolicy applies to the requests sent to one POS instance.
+type instancePolicy struct {
+	QPS     int           `json:"qps"` // requests per second beyond which requests are rejected
+	Breaker breakerPolicy `json:"breaker"`
+}
+
+// breakerPolicy configures the circuit breaker of a POS instance. It trips
+// (opens) after ConsecutiveFailures failures in a row or, if FailureRatio is
+// set, once that ratio of at least MinRequests requests failed within
+// Interval. After OpenTimeout it lets HalfOpenProbes requests through, and
+// closes again if they all succeed.
+type breakerPolicy struct {
+	ConsecutiveFailures uint32   `json:"consecutive_failures"`
+	FailureRatio        float64  `json:"failure_ratio"`
+	MinRequests         uint32   `json:"min_requests"`
+	Interval            duration `json:"interval"` // of the failure counts while closed, 0 never clears them
+	OpenTimeout         duration `json:"open_timeout"`
+	HalfOpenProbes      uint32   `json:"half_open_probes"`
+}
+
+// loadResiliencePolicy overrides the policy with the file at path, and reads
+// its per instance policies, which start from the default instance policy.
+func loadResiliencePolicy(path string, policy resiliencePolicy) (resiliencePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, err
+	}
+	var file struct {
+		Instances map[string]json.RawMessage `json:"instances"`
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return policy, fmt.Errorf("%s: %v", path, err)
+	}
+	policy.Instances = make(map[string]instancePolicy)
+	for instance, raw := range file.Instances {
+		p := policy.Default
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return policy, fmt.Errorf("%s: instance %s: %v", path, instance, err)
+		}
+		policy.Instances[instance] = p
+	}
+	return policy, nil
+}
+
+// instance returns the policy of the POS instance.
+func (p resiliencePolicy) instance(instance string) instancePolicy {
+	if ip, ok := p.Instances[instance]; ok {
+		return ip
+	}
+	return p.Default
+}
+
+// settings returns the gobreaker.Settings of the breaker of instance.
+func (p breakerPolicy) settings(instance string) gobreaker.Settings {
+	return gobreaker.Settings{
+		Name:        instance,
+		MaxRequests: p.HalfOpenProbes,
+		Interval:    time.Duration(p.Interval),
+		Timeout:     time.Duration(p.OpenTimeout),
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			if p.ConsecutiveFailures > 0 && counts.ConsecutiveFailures >= p.ConsecutiveFailures {
+				return true
+			}
+			return p.FailureRatio > 0 && counts.Requests >= p.MinRequests &&
+				float64(counts.TotalFailures)/float64(counts.Requests) >= p.FailureRatio
+		},
+		IsSuccessful: func(err error) bool {
+			// e.g. a 404 or a request we cancelled says nothing of the instance health
+			return err == nil || !transient(err)
+		},
+	}
+}
+
+// breaker returns the middleware sending requests through cb. An attempt the
+// caller gave up on, once the X-Request-Timeout of its report elapsed or it
+// disconnected, says nothing of the instance health: it is reported to cb as
+// a success, and its error returned as is. Timeouts of the retry policy count
+// as failures.
+func breaker(cb *gobreaker.CircuitBreaker) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var callerErr error
+			response, err := cb.Execute(func() (interface{}, error) {
+				response, err := next(ctx, request)
+				if err != nil && callerDone(ctx) {
+					callerErr = err
+					return nil, nil
+				}
+				return response, err
+			})
+			if callerErr != nil {
+				return nil, callerErr
+			}
+			return response, err
+		}
+	}
+}
+
+// callerDone reports whether the caller of the POS request gave up on it, as
+// opposed to the retry policy, whose MaxTime bounds ctx too.
+func callerDone(ctx context.Context) bool {
+	if callerErr, ok := ctx.Value(callerErrKey).(func() error); ok {
+		return callerErr() != nil
+	}
+	return ctx.Err() != nil
+}
+
+// transient reports whether a POS request that failed with err may succeed
+// when tried again, on the same or another instance. The POS requests are all
+// GETs, so retrying them is safe.
+func transient(err error) bool {
+	switch e := err.(type) {
+	case posStatusError:
+		return e.status >= 500 || e.status == 429
+	case *url.Error:
+		// the instance could not be reached or answer in time
+		return e.Err != context.Canceled
+	}
+	switch err {
+	case ratelimit.ErrLimited, gobreaker.ErrOpenState, gobreaker.ErrTooManyRequests, context.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// retry returns an endpoint sending a request to the instances of balancer
+// until it succeeds, fails with an error that is not transient, or the policy
+// gives up. Unlike lb.Retry it waits between attempts, with exponential
+// backoff and full jitter, and stops waiting when ctx is done. Failures are
+// reported as an lb.RetryError.
+func retry(policy retryPolicy, balancer lb.Balancer, retries func()) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ctx = context.WithValue(ctx, callerErrKey, ctx.Err)
+		if policy.MaxTime > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(policy.MaxTime))
+			defer cancel()
+		}
+
+		var final lb.RetryError
+		for attempt := 1; ; attempt++ {
+			e, err := balancer.Endpoint()
+			if err == nil {
+				var response interface{}
+				if response, err = e(ctx, request); err == nil {
+					return response, nil
+				}
+			}
+			final.RawErrors = append(final.RawErrors, err)
+			if attempt >= policy.MaxAttempts || !transient(err) || ctx.Err() != nil {
+				final.Final = err
+				return nil, final
+			}
+
+			retries()
+			select {
+			case <-time.After(policy.backoff(attempt)):
+			case <-ctx.Done():
+				final.Final = ctx.Err()
+				return nil, final
+			}
+		}
+	}
+}
+
+// backoff returns a random wait of up to Backoff x 2^(attempt-1), at most
+// MaxBackoff, before the retry following attempt.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	ceiling := time.Duration(p.Backoff)
+	for i := 1; i < attempt && ceiling < time.Duration(p.MaxBackoff); i++ {
+		ceiling *= 2
+	}
+	if ceiling > time.Duration(p.MaxBackoff) {
+		ceiling = time.Duration(p.MaxBackoff)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// duration is a time.Duration read from JSON strings such as "250ms".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
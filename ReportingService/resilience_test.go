@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/lb"
+	"github.com/sony/gobreaker"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// hangingPOS answers once ctx is done, the way an HTTP client endpoint fails
+// when the request context ends.
+func hangingPOS(ctx context.Context, _ interface{}) (interface{}, error) {
+	<-ctx.Done()
+	return nil, &url.Error{Op: "Get", URL: "http://pos/checks", Err: ctx.Err()}
+}
+
+func newTestBreaker(failures uint32) *gobreaker.CircuitBreaker {
+	return gobreaker.NewCircuitBreaker(breakerPolicy{
+		ConsecutiveFailures: failures,
+		OpenTimeout:         duration(time.Minute),
+		HalfOpenProbes:      1,
+	}.settings("pos"))
+}
+
+func TestBreakerIgnoresCallerDeadline(t *testing.T) {
+	cb := newTestBreaker(2)
+	e := retry(retryPolicy{MaxAttempts: 1, MaxTime: duration(time.Minute)},
+		lb.NewRoundRobin(sd.FixedEndpointer{breaker(cb)(hangingPOS)}), func() {})
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		_, err := e(ctx, posRequest{Path: checksPath})
+		cancel()
+		if status := errorStatus(err); status != http.StatusGatewayTimeout {
+			t.Errorf("attempt %d: status = %d, want %d (%v)", i, status, http.StatusGatewayTimeout, err)
+		}
+	}
+	if state := cb.State(); state != gobreaker.StateClosed {
+		t.Errorf("breaker %s after the caller's deadlines, want closed", state)
+	}
+}
+
+func TestBreakerCountsPolicyTimeout(t *testing.T) {
+	cb := newTestBreaker(2)
+	e := retry(retryPolicy{MaxAttempts: 1, MaxTime: duration(5 * time.Millisecond)},
+		lb.NewRoundRobin(sd.FixedEndpointer{breaker(cb)(hangingPOS)}), func() {})
+
+	for i := 0; i < 2; i++ {
+		if _, err := e(context.Background(), posRequest{Path: checksPath}); err == nil {
+			t.Fatalf("attempt %d: no error", i)
+		}
+	}
+	if state := cb.State(); state != gobreaker.StateOpen {
+		t.Errorf("breaker %s after the policy timeouts, want open", state)
+	}
+	_, err := e(context.Background(), posRequest{Path: checksPath})
+	if final := err.(lb.RetryError).Final; final != gobreaker.ErrOpenState {
+		t.Errorf("err = %v, want %v", final, gobreaker.ErrOpenState)
+	}
+}
+
+func TestBreakerCountsTransientFailures(t *testing.T) {
+	cb := newTestBreaker(2)
+	responses := []error{
+		posStatusError{checksPath, http.StatusNotFound, "404 Not Found"},
+		posStatusError{checksPath, http.StatusServiceUnavailable, "503 Service Unavailable"},
+		posStatusError{checksPath, http.StatusNotFound, "404 Not Found"},
+		posStatusError{checksPath, http.StatusBadGateway, "502 Bad Gateway"},
+		posStatusError{checksPath, http.StatusBadGateway, "502 Bad Gateway"},
+	}
+	for i, failure := range responses {
+		failure := failure
+		breaker(cb)(func(context.Context, interface{}) (interface{}, error) {
+			return nil, failure
+		})(context.Background(), posRequest{Path: checksPath})
+		// a 404 breaks the run of consecutive failures
+		if want := i == len(responses)-1; (cb.State() == gobreaker.StateOpen) != want {
+			t.Errorf("after response %d: breaker %s", i, cb.State())
+		}
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{posStatusError{checksPath, http.StatusInternalServerError, ""}, true},
+		{posStatusError{checksPath, http.StatusTooManyRequests, ""}, true},
+		{posStatusError{checksPath, http.StatusNotFound, ""}, false},
+		{&url.Error{Op: "Get", URL: "http://pos", Err: errors.New("connection refused")}, true},
+		{&url.Error{Op: "Get", URL: "http://pos", Err: context.DeadlineExceeded}, true},
+		{&url.Error{Op: "Get", URL: "http://pos", Err: context.Canceled}, false},
+		{ratelimit.ErrLimited, true},
+		{gobreaker.ErrOpenState, true},
+		{gobreaker.ErrTooManyRequests, true},
+		{context.DeadlineExceeded, true},
+		{errors.New("bad JSON"), false},
+	}
+	for _, tt := range tests {
+		if got := transient(tt.err); got != tt.want {
+			t.Errorf("transient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := retryPolicy{Backoff: duration(10 * time.Millisecond), MaxBackoff: duration(25 * time.Millisecond)}
+	ceilings := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond, 25 * time.Millisecond}
+	for i, ceiling := range ceilings {
+		for n := 0; n < 100; n++ {
+			if wait := policy.backoff(i + 1); wait < 0 || wait > ceiling {
+				t.Fatalf("backoff(%d) = %v, want at most %v", i+1, wait, ceiling)
+			}
+		}
+	}
+	if wait := (retryPolicy{Backoff: duration(time.Second)}).backoff(1); wait != 0 {
+		t.Errorf("backoff without MaxBackoff = %v, want 0", wait)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"github.com/go-kit/kit/endpoint"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// tracer starts the spans of ReportingService.
+var tracer = otel.Tracer("reportingservice")
+
+// injectTraceparent is the httptransport.ClientBefore passing the span of ctx
+// on to the POS in the traceparent header.
+func injectTraceparent(ctx context.Context, r *http.Request) context.Context {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+	return ctx
+}
+
+// traceClient starts a client span per request sent to instance, so every
+// attempt of the load balancer, rejected or not, shows up in the trace.
+func traceClient(instance string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			path := request.(posRequest).Path
+			ctx, span := tracer.Start(ctx, "GET "+path,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("pos.instance", instance), semconv.URLPath(path)),
+			)
+			defer func() {
+				endSpan(span, err)
+			}()
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingMiddleware traces the computation of a report from its POS data.
+type tracingMiddleware struct {
+	next ReportingService
+}
+
+func (mw tracingMiddleware) reporting(ctx context.Context, req ReportRequest) (output Report, err error) {
+	ctx, span := tracer.Start(ctx, "compute report", trace.WithAttributes(
+		attribute.String("report.type", req.ReportType),
+		attribute.String("report.time_interval", req.TimeInterval),
+		attribute.String("business.id", req.BusinessID),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("report.buckets", len(output.Data)))
+		endSpan(span, err)
+	}()
+
+	output, err = mw.next.reporting(ctx, req)
+	return
+}